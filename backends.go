@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"transjakarta-fleet/internal/broker"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/database"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/events/kafka"
+	mqttevents "transjakarta-fleet/internal/events/mqtt"
+	"transjakarta-fleet/internal/events/nats"
+	"transjakarta-fleet/internal/health"
+	"transjakarta-fleet/internal/lifecycle"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/rabbitmq"
+	"transjakarta-fleet/internal/repository"
+	"transjakarta-fleet/internal/repository/file"
+	"transjakarta-fleet/internal/repository/memory"
+	"transjakarta-fleet/internal/repository/postgres"
+	"transjakarta-fleet/internal/retry"
+)
+
+// backends are the storage, event bus and MQTT broker selected by the configuration.
+// Only the fields of the selected backends are set.
+type backends struct {
+	store     repository.Store
+	publisher events.Publisher
+
+	db      *sql.DB
+	file    *file.Store
+	bus     events.Backend // external event bus, nil when events stay in-process
+	busName string
+	rabbit  *rabbitmq.RabbitMQ
+	broker  *broker.Broker
+}
+
+// newBackends creates the selected backends without connecting to anything
+func newBackends(cfg *config.Config) (*backends, error) {
+	b := &backends{}
+
+	switch cfg.Storage {
+	case config.StoragePostgres, config.StorageTimescaleDB:
+		db, err := database.NewPostgresDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+		b.db = db
+		if cfg.Storage == config.StorageTimescaleDB {
+			b.store = postgres.NewTimescale(db, cfg)
+		} else {
+			b.store = postgres.New(db, cfg)
+		}
+	case config.StorageFile:
+		b.file = file.New(cfg.StoragePath)
+		b.store = b.file
+	case config.StorageMemory:
+		b.store = memory.New()
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+
+	switch cfg.EventBus {
+	case config.EventBusRabbitMQ:
+		b.rabbit = rabbitmq.NewRabbitMQ(cfg)
+		b.bus, b.busName = b.rabbit, "rabbitmq"
+	case config.EventBusNATS:
+		b.bus, b.busName = nats.New(cfg), "nats"
+	case config.EventBusKafka:
+		b.bus, b.busName = kafka.New(cfg), "kafka"
+	case config.EventBusMQTT:
+		b.bus, b.busName = mqttevents.New(cfg), "mqtt_events"
+	case config.EventBusMemory:
+		bus := events.NewBus()
+		bus.OnGeofenceEvent(logGeofenceEvent)
+		b.publisher = bus
+	default:
+		return nil, fmt.Errorf("unknown event bus %q", cfg.EventBus)
+	}
+	if b.bus != nil {
+		b.publisher = b.bus
+	}
+
+	if cfg.MQTTEmbedded {
+		b.broker = broker.New(cfg)
+	}
+
+	return b, nil
+}
+
+// registerChecks adds a readiness check for each external backend
+func (b *backends) registerChecks(checker *health.Checker) {
+	if b.db != nil {
+		checker.Register("postgres", b.db.PingContext)
+	}
+	if b.bus != nil {
+		checker.Register(b.busName, b.bus.Check)
+	}
+	if b.rabbit != nil {
+		checker.Register("geofence_worker", b.rabbit.CheckWorker)
+	}
+	if b.broker != nil {
+		checker.Register("mqtt_broker", b.broker.Check)
+	}
+}
+
+// connectStorage opens the storage file, or waits for the database and migrates it
+func (b *backends) connectStorage(ctx context.Context, cfg *config.Config) error {
+	if b.file != nil {
+		return b.file.Open()
+	}
+	if b.db == nil {
+		return nil
+	}
+	return retry.Do(ctx, "postgres", cfg.DBRetry, func(ctx context.Context) error {
+		if err := database.Connect(ctx, b.db, cfg); err != nil {
+			return err
+		}
+		if cfg.Storage == config.StorageTimescaleDB {
+			return database.RunTimescaleMigrations(b.db)
+		}
+		return database.RunMigrations(b.db)
+	})
+}
+
+// connectEventBus waits for the event broker and starts the workers consuming from it
+func (b *backends) connectEventBus(ctx context.Context, cfg *config.Config, lc *lifecycle.Manager) error {
+	if b.bus == nil {
+		return nil
+	}
+	policy := cfg.EventBusRetry
+	if b.rabbit != nil {
+		policy = cfg.RabbitMQRetry
+	}
+	err := retry.Do(ctx, b.busName, policy, func(context.Context) error {
+		return b.bus.Connect()
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only RabbitMQ events are consumed in-process; other buses serve external consumers
+	if b.rabbit == nil {
+		return nil
+	}
+
+	lc.Go("rabbitmq connection", b.rabbit.Watch)
+	lc.Go("geofence worker", func(ctx context.Context) error {
+		return rabbitmq.StartGeofenceWorker(ctx, b.rabbit)
+	})
+	return nil
+}
+
+// logGeofenceEvent stands in for the RabbitMQ geofence worker when events stay in-process
+func logGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) {
+	logging.FromContext(ctx).Info("Received geofence event",
+		"vehicle_id", event.VehicleID,
+		"latitude", event.Location.Latitude,
+		"longitude", event.Location.Longitude,
+		"timestamp", event.Timestamp,
+	)
+}
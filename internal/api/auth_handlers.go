@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Creates an API key for an integration. The plaintext key is only returned in this response.
+// @Description Keys belong to the caller's operator unless the caller is the super-tenant.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAPIKeyRequest true "Key name and role (viewer, dispatcher or admin)"
+// @Success 201 {object} models.APIKey
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	role := auth.Role(req.Role)
+	if !role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "role must be viewer, dispatcher or admin",
+		})
+		return
+	}
+
+	principal := currentPrincipal(c)
+	operatorID := req.OperatorID
+	if operatorID == "" {
+		operatorID = principal.OperatorID
+	}
+	if operatorID != principal.OperatorID && !principal.AllOperators {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "cannot create keys for another operator",
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.CreateKey(c.Request.Context(), req.Name, role, operatorID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description Lists the API keys of the caller's operator, including revoked ones, without their secrets
+// @Tags auth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys [get]
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListKeys(c.Request.Context(), operatorScope(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revokes an API key so it can no longer be used
+// @Tags auth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid key id",
+		})
+		return
+	}
+
+	err = h.apiKeyService.RevokeKey(c.Request.Context(), operatorScope(c), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("no active API key with id %d", id),
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to revoke API key", "key_id", id, "error", err)
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": "failed to revoke API key",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetCurrentPrincipal godoc
+// @Summary Get the authenticated caller
+// @Description Returns the subject and role the request was authenticated as
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.Principal
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/me [get]
+func (h *Handler) GetCurrentPrincipal(c *gin.Context) {
+	c.JSON(http.StatusOK, currentPrincipal(c))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/services"
+)
+
+// failingRevoke is an APIKeyService whose storage fails on revocation
+type failingRevoke struct {
+	APIKeyService
+	err error
+}
+
+func (f failingRevoke) RevokeKey(ctx context.Context, operatorID string, id int) error {
+	return f.err
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	s := newTestServer(t, nil)
+	other, err := s.apiKeys.CreateKey(context.Background(), "op2-viewer", auth.RoleViewer, "op2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim, err := s.apiKeys.CreateKey(context.Background(), "op1-integration", auth.RoleViewer, "op1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		key        string
+		storageErr error
+		wantStatus int
+		wantError  string // substring of the error message
+	}{
+		{name: "invalid ID", id: "abc", key: "op1-admin", wantStatus: http.StatusBadRequest},
+		{name: "another operator's key", id: strconv.Itoa(other.ID), key: "op1-admin", wantStatus: http.StatusNotFound},
+		{name: "own key", id: strconv.Itoa(victim.ID), key: "op1-admin", wantStatus: http.StatusNoContent},
+		{name: "already revoked", id: strconv.Itoa(victim.ID), key: "op1-admin", wantStatus: http.StatusNotFound, wantError: "no active API key"},
+		{name: "unknown key", id: "999", key: "super-admin", wantStatus: http.StatusNotFound},
+		{
+			name:       "storage failure",
+			id:         strconv.Itoa(other.ID),
+			key:        "super-admin",
+			storageErr: errors.New("connection refused by db.internal:5432"),
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "failed to revoke API key",
+		},
+		{
+			name:       "storage timeout",
+			id:         strconv.Itoa(other.ID),
+			key:        "super-admin",
+			storageErr: context.DeadlineExceeded,
+			wantStatus: http.StatusGatewayTimeout,
+			wantError:  "failed to revoke API key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := s.router
+			if tt.storageErr != nil {
+				router = gin.New()
+				SetupRoutes(router, s.cfg, s.vehicles, services.NewStatsService(s.store), failingRevoke{s.apiKeys, tt.storageErr})
+			}
+
+			rec := s.requestTo(router, http.MethodDelete, "/api/v1/auth/keys/"+tt.id, tt.key, nil, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body, tt.wantError)
+			}
+			if tt.storageErr != nil && strings.Contains(rec.Body.String(), tt.storageErr.Error()) {
+				t.Errorf("body = %s, leaks the storage error", rec.Body)
+			}
+		})
+	}
+}
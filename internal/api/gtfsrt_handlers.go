@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"transjakarta-fleet/internal/gtfsrt"
+)
+
+const protobufContentType = "application/x-protobuf"
+
+// GetGTFSVehiclePositions godoc
+// @Summary Get GTFS-Realtime VehiclePositions feed
+// @Description Serves the latest position of every vehicle of the caller's operator as a GTFS-Realtime feed. Returns protobuf by default, or JSON when format=json or Accept is application/json
+// @Tags gtfs-realtime
+// @Produce application/x-protobuf
+// @Produce json
+// @Param format query string false "Response format (pb or json)"
+// @Success 200 {object} gtfsrt.FeedMessage
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /gtfs-rt/vehicle-positions [get]
+func (h *Handler) GetGTFSVehiclePositions(c *gin.Context) {
+	locations, err := h.vehicleService.GetLatestLocations(c.Request.Context(), operatorScope(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	writeFeed(c, gtfsrt.NewVehiclePositionsFeed(locations, time.Now()))
+}
+
+// GetGTFSTripUpdates godoc
+// @Summary Get GTFS-Realtime TripUpdates feed
+// @Description Serves trip updates as a GTFS-Realtime feed. Returns protobuf by default, or JSON when format=json or Accept is application/json
+// @Tags gtfs-realtime
+// @Produce application/x-protobuf
+// @Produce json
+// @Param format query string false "Response format (pb or json)"
+// @Success 200 {object} gtfsrt.FeedMessage
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /gtfs-rt/trip-updates [get]
+func (h *Handler) GetGTFSTripUpdates(c *gin.Context) {
+	writeFeed(c, gtfsrt.NewTripUpdatesFeed(time.Now()))
+}
+
+func writeFeed(c *gin.Context, feed *gtfsrt.FeedMessage) {
+	format := c.Query("format")
+	if format == "" {
+		format = "pb"
+		if c.NegotiateFormat(protobufContentType, gin.MIMEJSON) == gin.MIMEJSON {
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "json":
+		c.JSON(http.StatusOK, feed)
+	case "pb":
+		c.Data(http.StatusOK, protobufContentType, feed.MarshalProto())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("unsupported feed format %q", format),
+		})
+	}
+}
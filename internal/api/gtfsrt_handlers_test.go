@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transjakarta-fleet/internal/gtfsrt"
+)
+
+func TestWriteFeed(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "protobuf by default", wantStatus: http.StatusOK, wantContentType: protobufContentType},
+		{name: "JSON negotiated", accept: "application/json", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "JSON requested", query: "?format=json", accept: protobufContentType, wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "protobuf requested", query: "?format=pb", accept: "application/json", wantStatus: http.StatusOK, wantContentType: protobufContentType},
+		{name: "unsupported format", query: "?format=xml", wantStatus: http.StatusBadRequest, wantContentType: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/gtfs-rt/trip-updates"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+
+			writeFeed(c, gtfsrt.NewTripUpdatesFeed(time.Now()))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+		})
+	}
+}
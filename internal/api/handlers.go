@@ -1,20 +1,34 @@
 package api
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"transjakarta-fleet/internal/services"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/export"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/models"
 )
 
 type Handler struct {
-	vehicleService *services.VehicleService
+	vehicleService VehicleService
+	statsService   StatsService
+	apiKeyService  APIKeyService
+	cfg            *config.Config
 }
 
-func NewHandler(vehicleService *services.VehicleService) *Handler {
+func NewHandler(vehicleService VehicleService, statsService StatsService, apiKeyService APIKeyService, cfg *config.Config) *Handler {
 	return &Handler{
 		vehicleService: vehicleService,
+		statsService:   statsService,
+		apiKeyService:  apiKeyService,
+		cfg:            cfg,
 	}
 }
 
@@ -25,16 +39,18 @@ func NewHandler(vehicleService *services.VehicleService) *Handler {
 // @Accept json
 // @Produce json
 // @Param vehicle_id path string true "Vehicle ID"
-// @Success 200 {object} models.VehicleLocation
+// @Success 200 {object} models.LocationStatus
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /vehicles/{vehicle_id}/location [get]
 func (h *Handler) GetLastLocation(c *gin.Context) {
 	vehicleID := c.Param("vehicle_id")
 
-	location, err := h.vehicleService.GetLastLocation(vehicleID)
+	location, err := h.vehicleService.GetLastLocation(c.Request.Context(), operatorScope(c), vehicleID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err, http.StatusNotFound), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -45,20 +61,129 @@ func (h *Handler) GetLastLocation(c *gin.Context) {
 
 // GetLocationHistory godoc
 // @Summary Get location history of a vehicle
-// @Description Retrieves location history for a vehicle within a specified time range
+// @Description Retrieves location history for a vehicle within a specified time range.
+// @Description The format parameter or the Accept header selects a JSON array (default), GeoJSON, GPX, CSV or KML.
 // @Tags vehicles
 // @Accept json
 // @Produce json
+// @Produce application/geo+json
+// @Produce application/gpx+xml
+// @Produce text/csv
+// @Produce application/vnd.google-earth.kml+xml
 // @Param vehicle_id path string true "Vehicle ID"
 // @Param start query int64 true "Start timestamp (Unix epoch)"
 // @Param end query int64 true "End timestamp (Unix epoch)"
+// @Param format query string false "Export format (json, geojson, gpx, csv or kml)"
 // @Success 200 {array} models.VehicleLocation
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /vehicles/{vehicle_id}/history [get]
 func (h *Handler) GetLocationHistory(c *gin.Context) {
 	vehicleID := c.Param("vehicle_id")
 
+	startTime, endTime, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = export.FormatForContentType(c.NegotiateFormat(
+			gin.MIMEJSON,
+			export.ContentType(export.FormatGeoJSON),
+			export.ContentType(export.FormatGPX),
+			export.ContentType(export.FormatCSV),
+			export.ContentType(export.FormatKML),
+		))
+	}
+	if format != "" && format != "json" {
+		h.exportLocationHistory(c, format, vehicleID, startTime, endTime)
+		return
+	}
+
+	locations, err := h.vehicleService.GetLocationHistory(c.Request.Context(), operatorScope(c), vehicleID, startTime, endTime)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if len(locations) == 0 {
+		c.JSON(http.StatusOK, []interface{}{})
+		return
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+// exportLocationHistory streams location history in an export format straight from the database to the client.
+// Headers are held back until the query returns its first row, so a failing query still gets an error status.
+func (h *Handler) exportLocationHistory(c *gin.Context, format, vehicleID string, startTime, endTime int64) {
+	buf := bufio.NewWriter(c.Writer)
+	writer, err := export.NewWriter(format, buf, vehicleID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	started := false
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("%s_%d_%d.%s", vehicleID, startTime, endTime, format)
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Status(http.StatusOK)
+		return writer.Begin()
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	err = h.vehicleService.StreamLocationHistory(c.Request.Context(), operatorScope(c), vehicleID, startTime, endTime, func(location *models.VehicleLocation) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(location)
+	})
+	if err != nil && !started {
+		logger.Error("Failed to export location history", "vehicle_id", vehicleID, "format", format, "error", err)
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = writer.End()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// Headers are already sent, so the truncated body is all the client gets
+		logger.Error("Failed to export location history", "vehicle_id", vehicleID, "format", format, "error", err)
+	}
+}
+
+// errorStatus maps a service error to an HTTP status: 504 when a query timed out,
+// otherwise fallback
+func errorStatus(err error, fallback int) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return fallback
+}
+
+// parseTimeRange reads the start and end query parameters, writing a 400 response if they are invalid
+func parseTimeRange(c *gin.Context) (int64, int64, bool) {
 	startStr := c.Query("start")
 	endStr := c.Query("end")
 
@@ -66,7 +191,7 @@ func (h *Handler) GetLocationHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "start and end query parameters are required",
 		})
-		return
+		return 0, 0, false
 	}
 
 	startTime, err := strconv.ParseInt(startStr, 10, 64)
@@ -74,7 +199,7 @@ func (h *Handler) GetLocationHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid start timestamp",
 		})
-		return
+		return 0, 0, false
 	}
 
 	endTime, err := strconv.ParseInt(endStr, 10, 64)
@@ -82,21 +207,15 @@ func (h *Handler) GetLocationHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid end timestamp",
 		})
-		return
+		return 0, 0, false
 	}
 
-	locations, err := h.vehicleService.GetLocationHistory(vehicleID, startTime, endTime)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+	if startTime > endTime {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "start must not be after end",
 		})
-		return
+		return 0, 0, false
 	}
 
-	if len(locations) == 0 {
-		c.JSON(http.StatusOK, []interface{}{})
-		return
-	}
-
-	c.JSON(http.StatusOK, locations)
+	return startTime, endTime, true
 }
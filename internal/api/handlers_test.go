@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+)
+
+func TestGetLocationHistory(t *testing.T) {
+	s := newTestServer(t, nil)
+	for i, vehicleID := range []string{"B1", "B1", "B1", "B2"} {
+		operatorID := map[string]string{"B1": "op1", "B2": "op2"}[vehicleID]
+		s.store.RestoreLocation(models.VehicleLocation{
+			VehicleID:  vehicleID,
+			Latitude:   -6.2,
+			Longitude:  106.8,
+			Timestamp:  1700000000 + int64(i)*10,
+			OperatorID: operatorID,
+		})
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		key             string
+		header          http.Header
+		wantStatus      int
+		wantTimestamps  []int64 // for JSON responses
+		wantContentType string  // for exports
+	}{
+		{
+			name:       "missing range",
+			path:       "/api/v1/vehicles/B1/history",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid start",
+			path:       "/api/v1/vehicles/B1/history?start=yesterday&end=1700000020",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "start after end",
+			path:       "/api/v1/vehicles/B1/history?start=1700000020&end=1700000000",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "whole track",
+			path:           "/api/v1/vehicles/B1/history?start=1700000000&end=1700000020",
+			wantStatus:     http.StatusOK,
+			wantTimestamps: []int64{1700000000, 1700000010, 1700000020},
+		},
+		{
+			name:           "part of the track",
+			path:           "/api/v1/vehicles/B1/history?start=1700000005&end=1700000015",
+			wantStatus:     http.StatusOK,
+			wantTimestamps: []int64{1700000010},
+		},
+		{
+			name:           "own vehicle",
+			path:           "/api/v1/vehicles/B1/history?start=1700000000&end=1700000000",
+			key:            "op1-dispatcher",
+			wantStatus:     http.StatusOK,
+			wantTimestamps: []int64{1700000000},
+		},
+		{
+			name:           "another operator's vehicle",
+			path:           "/api/v1/vehicles/B2/history?start=1700000000&end=1700000100",
+			key:            "op1-dispatcher",
+			wantStatus:     http.StatusOK,
+			wantTimestamps: []int64{},
+		},
+		{
+			name:            "GeoJSON export",
+			path:            "/api/v1/vehicles/B1/history?start=1700000000&end=1700000020&format=geojson",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/geo+json",
+		},
+		{
+			name:            "CSV export negotiated",
+			path:            "/api/v1/vehicles/B1/history?start=1700000000&end=1700000020",
+			header:          http.Header{"Accept": {"text/csv"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv",
+		},
+		{
+			name:            "empty export",
+			path:            "/api/v1/vehicles/B1/history?start=1600000000&end=1600000100&format=gpx",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/gpx+xml",
+		},
+		{
+			name:       "unsupported format",
+			path:       "/api/v1/vehicles/B1/history?start=1700000000&end=1700000020&format=shp",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == "" {
+				key = "super-admin"
+			}
+
+			rec := s.request(http.MethodGet, tt.path, key, tt.header, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			if tt.wantContentType != "" {
+				if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+					t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+				}
+				if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment; filename=B1_") {
+					t.Errorf("Content-Disposition = %q, want an attachment", got)
+				}
+				return
+			}
+
+			if tt.wantTimestamps != nil {
+				var locations []models.VehicleLocation
+				if err := json.Unmarshal(rec.Body.Bytes(), &locations); err != nil {
+					t.Fatalf("%v: %s", err, rec.Body)
+				}
+				timestamps := []int64{}
+				for _, location := range locations {
+					timestamps = append(timestamps, location.Timestamp)
+				}
+				if !reflect.DeepEqual(timestamps, tt.wantTimestamps) {
+					t.Errorf("timestamps = %v, want %v", timestamps, tt.wantTimestamps)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLastLocation(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) { cfg.PlausibilityMode = config.PlausibilityFlag })
+	now := time.Now().Unix()
+	s.store.RestoreLocation(models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: 106.8, Timestamp: now - 20, OperatorID: "op1"})
+	s.store.RestoreLocation(models.VehicleLocation{VehicleID: "B1", Timestamp: now - 10, OperatorID: "op1", Implausible: ingest.ReasonNullIsland})
+
+	tests := []struct {
+		name          string
+		path          string
+		key           string
+		wantStatus    int
+		wantTimestamp int64
+	}{
+		{name: "flagged location passed over", path: "/api/v1/vehicles/B1/location", key: "op1-viewer", wantStatus: http.StatusOK, wantTimestamp: now - 20},
+		{name: "revoked key", path: "/api/v1/vehicles/B1/location", key: "revoked", wantStatus: http.StatusUnauthorized},
+		{name: "no location", path: "/api/v1/vehicles/B2/location", key: "super-admin", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.request(http.MethodGet, tt.path, tt.key, nil, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var status models.LocationStatus
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatalf("%v: %s", err, rec.Body)
+			}
+			if status.Timestamp != tt.wantTimestamp {
+				t.Errorf("timestamp = %d, want %d", status.Timestamp, tt.wantTimestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
+	"transjakarta-fleet/internal/models"
+)
+
+// Rejection reasons specific to HTTP uploads, in addition to those of ingest.Validate
+const (
+	reasonVehicleNotPermitted = "vehicle_not_permitted"
+	reasonSaveFailed          = "save_failed"
+)
+
+// maxRecordSize bounds a single NDJSON line or a binary body
+const maxRecordSize = 1 << 20
+
+// errUploadTooLarge marks a body over the upload limits, which is refused
+// whole rather than partly saved
+var errUploadTooLarge = errors.New("upload too large")
+
+// ndjsonContentTypes select line-by-line decoding of the request body
+var ndjsonContentTypes = map[string]bool{
+	"application/x-ndjson":    true,
+	"application/ndjson":      true,
+	"application/jsonl":       true,
+	"application/x-jsonlines": true,
+}
+
+// IngestLocations godoc
+// @Summary Upload vehicle locations
+// @Description Saves locations sent as a single JSON object, a JSON array or newline-delimited JSON (Content-Type application/x-ndjson),
+// @Description or a Protobuf (application/x-protobuf, see /schemas/location.proto) or CBOR (application/cbor) location or batch.
+// @Description Each record goes through the same validation, geofence and driving rule checks as an MQTT message. Like an MQTT batch,
+// @Description records are saved and checked in timestamp order rather than the order sent.
+// @Description Rejected records are reported by their zero-based position; the others are still saved.
+// @Description Records older than the vehicle's newest location are saved as late and skip the geofence and driving rule checks;
+// @Description records dated more than MAX_CLOCK_SKEW in the future are rejected. Records at 0,0, outside the service area
+// @Description or implying an impossible jump from the previous point are saved marked implausible, or rejected if PLAUSIBILITY_MODE is reject.
+// @Description Operators other than the super-tenant can only upload locations of their own vehicles.
+// @Description Bodies larger than MAX_UPLOAD_BYTES or with more than MAX_UPLOAD_RECORDS records are refused with 413 and nothing is saved.
+// @Tags locations
+// @Accept json
+// @Accept application/x-ndjson
+// @Accept application/x-protobuf
+// @Accept application/cbor
+// @Produce json
+// @Param locations body []models.VehicleLocation true "A location, an array of locations or NDJSON"
+// @Success 200 {object} models.IngestResult "All records saved"
+// @Success 207 {object} models.IngestResult "Some records rejected"
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string "Upload too large, nothing saved"
+// @Failure 422 {object} models.IngestResult "No record saved"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /locations [post]
+func (h *Handler) IngestLocations(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.cfg.MaxUploadBytes))
+
+	// Vehicles the caller may report, nil when it may report any
+	var permitted map[string]bool
+	if scope := operatorScope(c); scope != "" {
+		vehicles, err := h.vehicleService.ListVehicles(ctx, scope)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		permitted = make(map[string]bool, len(vehicles))
+		for _, vehicle := range vehicles {
+			permitted[vehicle.VehicleID] = true
+		}
+	}
+
+	result := &models.IngestResult{Errors: []models.IngestError{}}
+	reject := func(index int, vehicleID, reason string, err error) {
+		result.Rejected++
+		result.Errors = append(result.Errors, models.IngestError{
+			Index:     index,
+			VehicleID: vehicleID,
+			Reason:    reason,
+			Error:     err.Error(),
+		})
+		metrics.HTTPLocations.WithLabelValues(reason).Inc()
+	}
+
+	encoding, ok := ingest.EncodingForContentType(c.ContentType())
+	if !ok {
+		encoding = ingest.EncodingJSON
+	}
+	ndjson := ndjsonContentTypes[c.ContentType()]
+
+	// Valid records are saved together once the body is read so they can be
+	// put in timestamp order; indexes maps them back to their position
+	var pending []*models.VehicleLocation
+	indexes := make(map[*models.VehicleLocation]int)
+
+	count, err := readRecords(c.Request.Body, encoding, ndjson, func(index int, location *models.VehicleLocation, err error) error {
+		if index >= h.cfg.MaxUploadRecords {
+			return fmt.Errorf("%w: more than %d records", errUploadTooLarge, h.cfg.MaxUploadRecords)
+		}
+		if err == nil {
+			err = ingest.Validate(location)
+		}
+
+		var rejection *ingest.Rejection
+		if errors.As(err, &rejection) {
+			reject(index, location.VehicleID, rejection.Reason, rejection)
+			return nil
+		}
+
+		if permitted != nil && !permitted[location.VehicleID] {
+			reject(index, location.VehicleID, reasonVehicleNotPermitted, fmt.Errorf("vehicle %s is not registered to your operator", location.VehicleID))
+			return nil
+		}
+
+		pending = append(pending, location)
+		indexes[location] = index
+		return nil
+	})
+
+	// Pending records are only saved once the whole body is known to fit
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		err = fmt.Errorf("%w: request body is larger than %d bytes", errUploadTooLarge, maxBytes.Limit)
+	}
+	if errors.Is(err, errUploadTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if len(pending) > 0 {
+		_, saveErr := h.vehicleService.SaveLocations(ctx, pending, func(location *models.VehicleLocation, err error) {
+			index := indexes[location]
+			delete(indexes, location)
+
+			var rejection *ingest.Rejection
+			switch {
+			case errors.As(err, &rejection):
+				reject(index, location.VehicleID, rejection.Reason, rejection)
+			case err != nil:
+				reject(index, location.VehicleID, reasonSaveFailed, err)
+			default:
+				result.Accepted++
+				if location.Late {
+					result.Late++
+				}
+				metrics.HTTPLocations.WithLabelValues("saved").Inc()
+			}
+		})
+		// Records after the one that failed were not attempted
+		if saveErr != nil {
+			for location, index := range indexes {
+				reject(index, location.VehicleID, reasonSaveFailed, fmt.Errorf("not saved: %w", saveErr))
+			}
+		}
+		sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Index < result.Errors[j].Index })
+	}
+
+	if ctx.Err() != nil {
+		logging.FromContext(ctx).Warn("Location upload aborted", "accepted", result.Accepted, "rejected", result.Rejected, "error", ctx.Err())
+		return
+	}
+	if err != nil {
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		reject(count, "", ingest.ReasonInvalidJSON, fmt.Errorf("%w; the remaining records were not read", err))
+	}
+
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "request body contains no locations",
+		})
+		return
+	}
+
+	logging.FromContext(ctx).Info("Ingested locations", "accepted", result.Accepted, "late", result.Late, "rejected", result.Rejected)
+
+	switch {
+	case result.Rejected == 0:
+		c.JSON(http.StatusOK, result)
+	case result.Accepted == 0:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	default:
+		c.JSON(http.StatusMultiStatus, result)
+	}
+}
+
+// readRecords decodes the body and calls fn with each location, or with the
+// error that kept a record from decoding, and returns how many records it read.
+// A Protobuf or CBOR body is a location or a batch. A JSON body is a JSON object,
+// a JSON array or a stream of JSON values. NDJSON lines are decoded on their own
+// so a malformed line only rejects itself; otherwise a syntax error ends the
+// body, since the decoder cannot skip past it. Reading also stops when fn
+// returns an error.
+func readRecords(body io.Reader, encoding string, ndjson bool, fn func(index int, location *models.VehicleLocation, err error) error) (int, error) {
+	count := 0
+	emit := func(raw []byte) error {
+		var location models.VehicleLocation
+		err := fn(count, &location, ingest.Decode(ingest.EncodingJSON, raw, &location))
+		count++
+		return err
+	}
+
+	if encoding != ingest.EncodingJSON {
+		raw, err := io.ReadAll(io.LimitReader(body, maxRecordSize+1))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if len(raw) == 0 {
+			return 0, nil
+		}
+		if len(raw) > maxRecordSize {
+			return 0, fmt.Errorf("%w: a Protobuf or CBOR body is limited to %d bytes", errUploadTooLarge, maxRecordSize)
+		}
+
+		locations, err := ingest.DecodeBatch(encoding, raw)
+		if err != nil {
+			return 1, fn(0, &models.VehicleLocation{}, err)
+		}
+		for i := range locations {
+			count++
+			if err := fn(i, &locations[i], nil); err != nil {
+				return count, err
+			}
+		}
+		return count, nil
+	}
+
+	if ndjson {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			if err := emit(line); err != nil {
+				return count, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return count, fmt.Errorf("failed to read record %d: %w", count, err)
+		}
+		return count, nil
+	}
+
+	reader := bufio.NewReader(body)
+	decoder := json.NewDecoder(reader)
+
+	first, err := firstByte(reader)
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return 0, fmt.Errorf("invalid JSON: %w", err)
+		}
+		for decoder.More() {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return count, fmt.Errorf("invalid JSON in record %d: %w", count, err)
+			}
+			if err := emit(raw); err != nil {
+				return count, err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return count, fmt.Errorf("invalid JSON after record %d: %w", count-1, err)
+		}
+		return count, nil
+	}
+
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("invalid JSON in record %d: %w", count, err)
+		}
+		if err := emit(raw); err != nil {
+			return count, err
+		}
+	}
+}
+
+// firstByte returns the first non-whitespace byte of reader without consuming it
+func firstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+)
+
+func TestIngestLocations(t *testing.T) {
+	now := time.Now().Unix()
+	at := func(vehicleID string, lat, lon float64, timestamp int64) string {
+		return `{"vehicle_id":"` + vehicleID + `","latitude":` + strconv.FormatFloat(lat, 'f', -1, 64) +
+			`,"longitude":` + strconv.FormatFloat(lon, 'f', -1, 64) + `,"timestamp":` + strconv.FormatInt(timestamp, 10) + `}`
+	}
+	// Nearby points in the service area, spaced a second apart
+	point := func(vehicleID string, secondsAgo int64) string {
+		return at(vehicleID, -6.2+float64(secondsAgo)*1e-5, 106.8, now-secondsAgo)
+	}
+	array := func(records ...string) string {
+		return "[" + strings.Join(records, ",") + "]"
+	}
+
+	var protobuf []byte
+	protobuf = protowire.AppendTag(protobuf, 1, protowire.BytesType)
+	protobuf = protowire.AppendString(protobuf, "B1")
+	protobuf = protowire.AppendTag(protobuf, 2, protowire.Fixed64Type)
+	protobuf = protowire.AppendFixed64(protobuf, math.Float64bits(-6.2))
+	protobuf = protowire.AppendTag(protobuf, 3, protowire.Fixed64Type)
+	protobuf = protowire.AppendFixed64(protobuf, math.Float64bits(106.8))
+	protobuf = protowire.AppendTag(protobuf, 4, protowire.VarintType)
+	protobuf = protowire.AppendVarint(protobuf, uint64(now))
+
+	type rejection struct {
+		index  int
+		reason string
+	}
+	tests := []struct {
+		name         string
+		key          string
+		modify       func(cfg *config.Config)
+		contentType  string
+		body         string
+		wantStatus   int
+		wantAccepted int
+		wantLate     int
+		wantErrors   []rejection
+		wantGeofence int
+	}{
+		{
+			name:         "single object",
+			body:         point("B1", 0),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 1,
+		},
+		{
+			name:         "unsorted backfill",
+			body:         array(point("B1", 10), point("B1", 30), point("B1", 20), point("B1", 40)),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 4,
+		},
+		{
+			name:         "duplicate point is late",
+			body:         array(point("B1", 10), point("B1", 10)),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 2,
+			wantLate:     1,
+		},
+		{
+			name:         "invalid records reported by position",
+			body:         array(point("B1", 30), at("B1", 95, 106.8, now-20), point("B1", 10), at("", -6.2, 106.8, now)),
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 2,
+			wantErrors:   []rejection{{1, ingest.ReasonInvalidLatitude}, {3, ingest.ReasonMissingVehicleID}},
+		},
+		{
+			name:         "null island",
+			modify:       func(cfg *config.Config) { cfg.PlausibilityMode = config.PlausibilityReject },
+			body:         array(at("B1", 0, 0, now-20), point("B1", 10)),
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 1,
+			wantErrors:   []rejection{{0, ingest.ReasonNullIsland}},
+		},
+		{
+			name:         "future timestamp",
+			body:         array(point("B1", 10), at("B1", -6.2, 106.8, now+3600)),
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 1,
+			wantErrors:   []rejection{{1, ingest.ReasonFutureTimestamp}},
+		},
+		{
+			name:       "nothing saved",
+			body:       array(at("B1", 95, 106.8, now), at("B1", -6.2, 190, now)),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []rejection{{0, ingest.ReasonInvalidLatitude}, {1, ingest.ReasonInvalidLongitude}},
+		},
+		{
+			name:         "another operator's vehicle",
+			key:          "op1-dispatcher",
+			body:         array(point("B1", 10), point("B2", 0)),
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 1,
+			wantErrors:   []rejection{{1, reasonVehicleNotPermitted}},
+		},
+		{
+			name:         "malformed NDJSON line",
+			contentType:  "application/x-ndjson",
+			body:         point("B1", 20) + "\n{\"vehicle_id\":\n" + point("B1", 10) + "\n",
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 2,
+			wantErrors:   []rejection{{1, ingest.ReasonInvalidJSON}},
+		},
+		{
+			name:         "malformed JSON ends the array",
+			body:         "[" + point("B1", 20) + "," + point("B1", 10) + `,{"vehicle_id":}]`,
+			wantStatus:   http.StatusMultiStatus,
+			wantAccepted: 2,
+			wantErrors:   []rejection{{2, ingest.ReasonInvalidJSON}},
+		},
+		{
+			name:         "Protobuf",
+			contentType:  "application/x-protobuf",
+			body:         string(protobuf),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 1,
+		},
+		{
+			name:         "entering the geofence",
+			body:         at("B1", -6.1751, 106.8270, now),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 1,
+			wantGeofence: 1,
+		},
+		{
+			name:         "records at the limit",
+			modify:       func(cfg *config.Config) { cfg.MaxUploadRecords = 2 },
+			body:         array(point("B1", 10), point("B1", 0)),
+			wantStatus:   http.StatusOK,
+			wantAccepted: 2,
+		},
+		{
+			name:       "too many records",
+			modify:     func(cfg *config.Config) { cfg.MaxUploadRecords = 2 },
+			body:       array(point("B1", 20), point("B1", 10), point("B1", 0)),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "body too large",
+			modify:      func(cfg *config.Config) { cfg.MaxUploadBytes = 150 },
+			contentType: "application/x-ndjson",
+			body:        point("B1", 20) + "\n" + point("B1", 10) + "\n",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "empty array",
+			body:       "[]",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty body",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.modify)
+			key := tt.key
+			if key == "" {
+				key = "super-admin"
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			rec := s.request(http.MethodPost, "/api/v1/locations", key, http.Header{"Content-Type": {contentType}}, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			saved := 0
+			if err := s.store.StreamFleetHistory(context.Background(), "", 0, now+60, func(*models.VehicleLocation) error {
+				saved++
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if saved != tt.wantAccepted {
+				t.Errorf("saved %d locations, want %d", saved, tt.wantAccepted)
+			}
+			if tt.wantStatus == http.StatusBadRequest || tt.wantStatus == http.StatusRequestEntityTooLarge {
+				return
+			}
+
+			var result models.IngestResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("%v: %s", err, rec.Body)
+			}
+			var errs []rejection
+			for _, e := range result.Errors {
+				errs = append(errs, rejection{e.Index, e.Reason})
+			}
+			if result.Accepted != tt.wantAccepted || result.Late != tt.wantLate || result.Rejected != len(tt.wantErrors) {
+				t.Errorf("accepted %d, late %d, rejected %d, want %d, %d, %d",
+					result.Accepted, result.Late, result.Rejected, tt.wantAccepted, tt.wantLate, len(tt.wantErrors))
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrors) {
+				t.Errorf("errors = %+v, want %+v", errs, tt.wantErrors)
+			}
+			if got := len(s.bus.GeofenceEvents()); got != tt.wantGeofence {
+				t.Errorf("published %d geofence events, want %d", got, tt.wantGeofence)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/ratelimit"
+	"transjakarta-fleet/internal/services"
+)
+
+const principalContextKey = "principal"
+
+// Authenticate resolves the caller from an X-API-Key header or an Authorization bearer JWT.
+// When authentication is disabled every request is treated as an anonymous super-tenant admin.
+func Authenticate(apiKeyService APIKeyService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.AuthEnabled {
+			c.Set(principalContextKey, &auth.Principal{
+				Subject:      "anonymous",
+				Role:         auth.RoleAdmin,
+				OperatorID:   cfg.SuperTenantID,
+				AllOperators: true,
+			})
+			c.Next()
+			return
+		}
+
+		var (
+			principal *auth.Principal
+			err       error
+		)
+		key := c.GetHeader("X-API-Key")
+		token, hasToken := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		switch {
+		case key != "":
+			principal, err = apiKeyService.Authenticate(c.Request.Context(), key)
+			if err != nil && !errors.Is(err, services.ErrInvalidAPIKey) {
+				logging.FromContext(c.Request.Context()).Error("Failed to authenticate API key", "error", err)
+				c.AbortWithStatusJSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+					"error": "failed to authenticate request",
+				})
+				return
+			}
+		case hasToken && cfg.JWTSecret != "":
+			principal, err = auth.ParseToken(token, []byte(cfg.JWTSecret))
+		default:
+			err = errors.New("missing credentials")
+		}
+
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="transjakarta-fleet"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		principal.AllOperators = principal.OperatorID == cfg.SuperTenantID
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+// RequireRole aborts the request unless the caller's role grants at least role
+func RequireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := currentPrincipal(c)
+		if principal == nil || !principal.Role.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "requires " + string(role) + " role",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAllOperators aborts the request unless the caller belongs to the super-tenant
+func RequireAllOperators() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := currentPrincipal(c)
+		if principal == nil || !principal.AllOperators {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "requires the super-tenant",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// operatorScope returns the operator the caller's queries are restricted to,
+// or an empty string if the caller can see all operators
+func operatorScope(c *gin.Context) string {
+	return currentPrincipal(c).OperatorScope()
+}
+
+// RateLimit rejects requests with 429 once the caller's token bucket in limiter is empty.
+// Callers are identified by API key or token subject, falling back to the client IP.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := limiter.Allow(rateLimitKey(c), time.Now())
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			abortRateLimited(c, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPRateLimit rejects requests with 429 once the client IP's token bucket in limiter
+// is empty. It runs before Authenticate so requests with bad credentials are counted
+// too, and leaves the RateLimit headers to the per-caller limit that follows it.
+func IPRateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if result := limiter.Allow("ip:"+c.ClientIP(), time.Now()); !result.Allowed {
+			abortRateLimited(c, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortRateLimited(c *gin.Context, result ratelimit.Result) {
+	c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "rate limit exceeded",
+	})
+}
+
+func rateLimitKey(c *gin.Context) string {
+	principal := currentPrincipal(c)
+	switch {
+	case principal == nil || principal.Subject == "anonymous":
+		return "ip:" + c.ClientIP()
+	case principal.KeyID != 0:
+		return "key:" + strconv.Itoa(principal.KeyID)
+	default:
+		return "sub:" + principal.OperatorID + "/" + principal.Subject
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// currentPrincipal returns the caller set by Authenticate, or nil
+func currentPrincipal(c *gin.Context) *auth.Principal {
+	value, ok := c.Get(principalContextKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*auth.Principal)
+	return principal
+}
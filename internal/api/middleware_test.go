@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/config"
+)
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/api/v1/vehicles", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", method: http.MethodGet, path: "/api/v1/vehicles", header: http.Header{"X-Api-Key": {"tjf_unknown"}}, wantStatus: http.StatusUnauthorized},
+		{name: "revoked key", method: http.MethodGet, path: "/api/v1/vehicles", key: "revoked", wantStatus: http.StatusUnauthorized},
+		{name: "viewer key", method: http.MethodGet, path: "/api/v1/vehicles", key: "op1-viewer", wantStatus: http.StatusOK},
+		{name: "bearer token", method: http.MethodGet, path: "/api/v1/vehicles", header: bearer(token(t, testJWTSecret, auth.RoleViewer, "op1", time.Hour)), wantStatus: http.StatusOK},
+		{name: "expired token", method: http.MethodGet, path: "/api/v1/vehicles", header: bearer(token(t, testJWTSecret, auth.RoleViewer, "op1", -time.Minute)), wantStatus: http.StatusUnauthorized},
+		{name: "token signed with another secret", method: http.MethodGet, path: "/api/v1/vehicles", header: bearer(token(t, "other", auth.RoleAdmin, "op1", time.Hour)), wantStatus: http.StatusUnauthorized},
+		{name: "viewer reading history", method: http.MethodGet, path: "/api/v1/vehicles/B1/history?start=0&end=1", key: "op1-viewer", wantStatus: http.StatusForbidden},
+		{name: "viewer uploading", method: http.MethodPost, path: "/api/v1/locations", key: "op1-viewer", wantStatus: http.StatusForbidden},
+		{name: "dispatcher managing keys", method: http.MethodGet, path: "/api/v1/auth/keys", key: "op1-dispatcher", wantStatus: http.StatusForbidden},
+		{name: "operator admin managing keys", method: http.MethodGet, path: "/api/v1/auth/keys", key: "op1-admin", wantStatus: http.StatusOK},
+		{name: "operator admin registering a vehicle", method: http.MethodPut, path: "/api/v1/vehicles/B3", key: "op1-admin", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.request(tt.method, tt.path, tt.key, tt.header, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimitEnabled = true
+		cfg.IPRateLimitBurst = 3
+		cfg.RateLimitBurst = 2
+		cfg.HistoryRateLimitBurst = 1
+	})
+
+	// Requests with a bad key are counted before authentication rejects them
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rec := s.request(http.MethodGet, "/api/v1/vehicles", "", http.Header{"X-Api-Key": {"tjf_guess"}}, "")
+		if rec.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 without a Retry-After header")
+		}
+	}
+}
+
+func TestRateLimitPerRouteGroup(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimitEnabled = true
+		cfg.RateLimitBurst = 2
+		cfg.HistoryRateLimitBurst = 1
+	})
+
+	// The history routes report their own limit only
+	rec := s.request(http.MethodGet, "/api/v1/vehicles/B1/history?start=0&end=1", "super-admin", nil, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("history: status %d, RateLimit-Limit %q, want 200 and 1", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := s.request(http.MethodGet, "/api/v1/vehicles/B1/history?start=0&end=1", "super-admin", nil, ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second history request: status %d, want 429", rec.Code)
+	}
+
+	// The general limit is separate
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := s.request(http.MethodGet, "/api/v1/vehicles", "super-admin", nil, "")
+		if rec.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i, rec.Code, want)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("request %d: RateLimit-Limit = %q, want 2", i, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+	// Uploads and key management have buckets of their own
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/locations"},
+		{http.MethodGet, "/api/v1/auth/keys"},
+	} {
+		rec := s.request(route.method, route.path, "super-admin", nil, "")
+		if rec.Code == http.StatusTooManyRequests || rec.Header().Get("RateLimit-Remaining") != "1" {
+			t.Errorf("%s %s: status %d, RateLimit-Remaining %q, want a full bucket", route.method, route.path, rec.Code, rec.Header().Get("RateLimit-Remaining"))
+		}
+	}
+}
@@ -2,19 +2,70 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
-	"transjakarta-fleet/internal/services"
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ratelimit"
 )
 
-func SetupRoutes(router *gin.Engine, vehicleService *services.VehicleService) {
-	handler := NewHandler(vehicleService)
+func SetupRoutes(router *gin.Engine, cfg *config.Config, vehicleService VehicleService, statsService StatsService, apiKeyService APIKeyService) {
+	handler := NewHandler(vehicleService, statsService, apiKeyService, cfg)
+
+	// Per-client limits: the general settings and stricter ones for history
+	// and analytics, which scan large time windows. Each route group has its
+	// own limiter, shared by its routes, so bulk uploads do not use up the
+	// budget of live reads. A per-IP limit ahead of authentication also counts
+	// requests with bad credentials.
+	limit := func(middleware func(*ratelimit.Limiter) gin.HandlerFunc, rate float64, burst int) gin.HandlerFunc {
+		if !cfg.RateLimitEnabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return middleware(ratelimit.New(rate, burst))
+	}
+	ipLimit := limit(IPRateLimit, cfg.IPRateLimitRPS, cfg.IPRateLimitBurst)
+	generalLimit := func() gin.HandlerFunc {
+		return limit(RateLimit, cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
+	historyLimit := limit(RateLimit, cfg.HistoryRateLimitRPS, cfg.HistoryRateLimitBurst)
 
 	// API v1 group
-	v1 := router.Group("/api/v1")
+	v1 := router.Group("/api/v1", ipLimit, Authenticate(apiKeyService, cfg))
 	{
-		vehicles := v1.Group("/vehicles")
+		// Live positions, also consumed by third-party integrations
+		viewer := v1.Group("", generalLimit(), RequireRole(auth.RoleViewer))
+		{
+			viewer.GET("/vehicles", handler.ListVehicles)
+			viewer.GET("/vehicles/:vehicle_id/location", handler.GetLastLocation)
+			viewer.GET("/gtfs-rt/vehicle-positions", handler.GetGTFSVehiclePositions)
+			viewer.GET("/gtfs-rt/trip-updates", handler.GetGTFSTripUpdates)
+			viewer.GET("/auth/me", handler.GetCurrentPrincipal)
+		}
+
+		// Movement history and analytics
+		dispatcher := v1.Group("", historyLimit, RequireRole(auth.RoleDispatcher))
+		{
+			dispatcher.GET("/vehicles/:vehicle_id/history", handler.GetLocationHistory)
+			dispatcher.GET("/vehicles/:vehicle_id/stats", handler.GetVehicleStats)
+			dispatcher.GET("/fleet/stats", handler.GetFleetStats)
+		}
+
+		// Location uploads from units without MQTT and from backfill scripts
+		uploader := v1.Group("", generalLimit(), RequireRole(auth.RoleDispatcher))
+		{
+			uploader.POST("/locations", handler.IngestLocations)
+		}
+
+		// Key management, scoped to the caller's operator
+		admin := v1.Group("/auth", generalLimit(), RequireRole(auth.RoleAdmin))
+		{
+			admin.POST("/keys", handler.CreateAPIKey)
+			admin.GET("/keys", handler.ListAPIKeys)
+			admin.DELETE("/keys/:id", handler.RevokeAPIKey)
+		}
+
+		// Vehicle to operator assignment
+		superAdmin := v1.Group("", generalLimit(), RequireRole(auth.RoleAdmin), RequireAllOperators())
 		{
-			vehicles.GET("/:vehicle_id/location", handler.GetLastLocation)
-			vehicles.GET("/:vehicle_id/history", handler.GetLocationHistory)
+			superAdmin.PUT("/vehicles/:vehicle_id", handler.RegisterVehicle)
 		}
 	}
 }
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/repository/memory"
+	"transjakarta-fleet/internal/services"
+)
+
+const testJWTSecret = "test-secret"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer serves the API from memory. B1 is registered to op1 and B2 to op2.
+type testServer struct {
+	cfg      *config.Config
+	router   *gin.Engine
+	store    *memory.Store
+	bus      *events.Memory
+	vehicles *services.VehicleService
+	apiKeys  *services.APIKeyService
+	keys     map[string]string // plaintext API keys by name
+}
+
+func newTestServer(t *testing.T, modify func(cfg *config.Config)) *testServer {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.JWTSecret = testJWTSecret
+	cfg.RateLimitEnabled = false
+	if modify != nil {
+		modify(cfg)
+	}
+
+	store := memory.New()
+	bus := events.NewMemory()
+	vehicleService := services.NewVehicleService(store, store, bus, cfg, services.NewPresenceMonitor(bus, cfg))
+	apiKeyService := services.NewAPIKeyService(store, cfg)
+
+	s := &testServer{
+		cfg:      cfg,
+		router:   gin.New(),
+		store:    store,
+		bus:      bus,
+		vehicles: vehicleService,
+		apiKeys:  apiKeyService,
+		keys:     make(map[string]string),
+	}
+	SetupRoutes(s.router, cfg, vehicleService, services.NewStatsService(store), apiKeyService)
+
+	for vehicleID, operatorID := range map[string]string{"B1": "op1", "B2": "op2"} {
+		if _, err := store.RegisterVehicle(ctx, vehicleID, operatorID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := []struct {
+		name       string
+		role       auth.Role
+		operatorID string
+	}{
+		{"super-admin", auth.RoleAdmin, cfg.SuperTenantID},
+		{"op1-admin", auth.RoleAdmin, "op1"},
+		{"op1-dispatcher", auth.RoleDispatcher, "op1"},
+		{"op1-viewer", auth.RoleViewer, "op1"},
+		{"revoked", auth.RoleAdmin, cfg.SuperTenantID},
+	}
+	for _, k := range keys {
+		key, err := apiKeyService.CreateKey(ctx, k.name, k.role, k.operatorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.keys[k.name] = key.Key
+		if k.name == "revoked" {
+			if err := apiKeyService.RevokeKey(ctx, "", key.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return s
+}
+
+// request sends a request as the API key named key, if any, with extra headers
+func (s *testServer) request(method, path, key string, header http.Header, body string) *httptest.ResponseRecorder {
+	return s.requestTo(s.router, method, path, key, header, body)
+}
+
+// requestTo is request for another router, set up with different services
+func (s *testServer) requestTo(router *gin.Engine, method, path, key string, header http.Header, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:1234"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if key != "" {
+		req.Header.Set("X-API-Key", s.keys[key])
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func token(t *testing.T, secret string, role auth.Role, operatorID string, expiresIn time.Duration) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		Role:     role,
+		Operator: operatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "dispatch-console",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/models"
+)
+
+// The handlers depend on these interfaces rather than the concrete services
+// so they can be exercised with in-memory implementations.
+
+// VehicleService accepts locations and serves live positions, history and vehicle registration
+type VehicleService interface {
+	SaveLocations(ctx context.Context, locations []*models.VehicleLocation, done func(*models.VehicleLocation, error)) (int, error)
+	GetLastLocation(ctx context.Context, operatorID, vehicleID string) (*models.LocationStatus, error)
+	GetLatestLocations(ctx context.Context, operatorID string) ([]*models.LocationStatus, error)
+	GetLocationHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64) ([]*models.VehicleLocation, error)
+	StreamLocationHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) error
+	RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (*models.Vehicle, error)
+	ListVehicles(ctx context.Context, operatorID string) ([]*models.Vehicle, error)
+}
+
+// StatsService computes trip and distance statistics
+type StatsService interface {
+	GetVehicleStats(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64) (*models.VehicleStats, error)
+	GetFleetStats(ctx context.Context, operatorID string, startTime, endTime int64) (*models.FleetStats, error)
+}
+
+// APIKeyService manages API keys and resolves them to principals.
+// Authenticate returns services.ErrInvalidAPIKey for unknown or revoked keys,
+// RevokeKey an error wrapping repository.ErrNotFound for them.
+type APIKeyService interface {
+	CreateKey(ctx context.Context, name string, role auth.Role, operatorID string) (*models.APIKey, error)
+	ListKeys(ctx context.Context, operatorID string) ([]*models.APIKey, error)
+	RevokeKey(ctx context.Context, operatorID string, id int) error
+	Authenticate(ctx context.Context, plaintext string) (*auth.Principal, error)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetVehicleStats godoc
+// @Summary Get trip and distance statistics of a vehicle
+// @Description Computes distance driven per day, moving and idle time, max and average speed and number of stops for a vehicle within a time range
+// @Tags vehicles
+// @Accept json
+// @Produce json
+// @Param vehicle_id path string true "Vehicle ID"
+// @Param start query int64 true "Start timestamp (Unix epoch)"
+// @Param end query int64 true "End timestamp (Unix epoch)"
+// @Success 200 {object} models.VehicleStats
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /vehicles/{vehicle_id}/stats [get]
+func (h *Handler) GetVehicleStats(c *gin.Context) {
+	vehicleID := c.Param("vehicle_id")
+
+	startTime, endTime, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.statsService.GetVehicleStats(c.Request.Context(), operatorScope(c), vehicleID, startTime, endTime)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetFleetStats godoc
+// @Summary Get fleet-wide trip and distance statistics
+// @Description Computes totals across all vehicles of the caller's operator that reported within a time range, with a per-vehicle breakdown
+// @Tags fleet
+// @Accept json
+// @Produce json
+// @Param start query int64 true "Start timestamp (Unix epoch)"
+// @Param end query int64 true "End timestamp (Unix epoch)"
+// @Success 200 {object} models.FleetStats
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /fleet/stats [get]
+func (h *Handler) GetFleetStats(c *gin.Context) {
+	startTime, endTime, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.statsService.GetFleetStats(c.Request.Context(), operatorScope(c), startTime, endTime)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+)
+
+// ListVehicles godoc
+// @Summary List registered vehicles
+// @Description Lists the vehicles registered to the caller's operator
+// @Tags vehicles
+// @Produce json
+// @Success 200 {array} models.Vehicle
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /vehicles [get]
+func (h *Handler) ListVehicles(c *gin.Context) {
+	vehicles, err := h.vehicleService.ListVehicles(c.Request.Context(), operatorScope(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, vehicles)
+}
+
+// RegisterVehicle godoc
+// @Summary Assign a vehicle to an operator
+// @Description Registers a vehicle to an operator, or moves it to another one. Only the super-tenant can register vehicles.
+// @Tags vehicles
+// @Accept json
+// @Produce json
+// @Param vehicle_id path string true "Vehicle ID"
+// @Param request body models.RegisterVehicleRequest true "Operator the vehicle belongs to"
+// @Success 200 {object} models.Vehicle
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /vehicles/{vehicle_id} [put]
+func (h *Handler) RegisterVehicle(c *gin.Context) {
+	var req models.RegisterVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	vehicleID := c.Param("vehicle_id")
+	if err := ingest.ValidateVehicleID(vehicleID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	vehicle, err := h.vehicleService.RegisterVehicle(c.Request.Context(), vehicleID, req.OperatorID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, vehicle)
+}
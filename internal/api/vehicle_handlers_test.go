@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"transjakarta-fleet/internal/models"
+)
+
+func TestOperatorScope(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	var vehicles []models.Vehicle
+	rec := s.request(http.MethodGet, "/api/v1/vehicles", "op1-viewer", nil, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &vehicles); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(vehicles) != 1 || vehicles[0].VehicleID != "B1" {
+		t.Errorf("op1 sees vehicles %+v, want only B1", vehicles)
+	}
+
+	rec = s.request(http.MethodGet, "/api/v1/vehicles", "super-admin", nil, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &vehicles); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(vehicles) != 2 {
+		t.Errorf("the super-tenant sees vehicles %+v, want B1 and B2", vehicles)
+	}
+}
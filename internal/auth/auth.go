@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Role string
+
+const (
+	RoleViewer     Role = "viewer"
+	RoleDispatcher Role = "dispatcher"
+	RoleAdmin      Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:     1,
+	RoleDispatcher: 2,
+	RoleAdmin:      3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Allows reports whether r grants at least the permissions of required
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject    string `json:"subject"`
+	Role       Role   `json:"role"`
+	OperatorID string `json:"operator_id"`
+	// AllOperators is set for callers of the super-tenant, who can see every operator's data
+	AllOperators bool `json:"all_operators"`
+	// KeyID is set when the caller authenticated with an API key
+	KeyID int `json:"key_id,omitempty"`
+}
+
+// OperatorScope returns the operator the caller's queries are restricted to,
+// or an empty string if the caller can see all operators
+func (p *Principal) OperatorScope() string {
+	if p.AllOperators {
+		return ""
+	}
+	return p.OperatorID
+}
+
+// Claims are the JWT claims accepted from bearer tokens
+type Claims struct {
+	Role     Role   `json:"role"`
+	Operator string `json:"operator"`
+	jwt.RegisteredClaims
+}
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// ParseToken validates an HS256-signed JWT and returns its principal
+func ParseToken(tokenString string, secret []byte) (*Principal, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	if !claims.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidToken, claims.Role)
+	}
+	if claims.Operator == "" {
+		return nil, fmt.Errorf("%w: missing operator", ErrInvalidToken)
+	}
+
+	return &Principal{
+		Subject:    claims.Subject,
+		Role:       claims.Role,
+		OperatorID: claims.Operator,
+	}, nil
+}
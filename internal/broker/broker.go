@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"transjakarta-fleet/internal/config"
+)
+
+// Broker is an MQTT broker running in-process, used instead of an external
+// broker in embedded mode. Devices and the location subscriber connect to it
+// over TCP like they would to Mosquitto.
+type Broker struct {
+	cfg     *config.Config
+	server  *mqtt.Server
+	running atomic.Bool
+}
+
+func New(cfg *config.Config) *Broker {
+	return &Broker{
+		cfg: cfg,
+		server: mqtt.New(&mqtt.Options{
+			Logger: slog.Default().With("component", "mqtt-broker"),
+		}),
+	}
+}
+
+// Start listens on the configured address and serves clients in the background.
+// When MQTT credentials are configured only clients presenting them may connect.
+func (b *Broker) Start() error {
+	var err error
+	if b.cfg.MQTTUsername != "" {
+		err = b.server.AddHook(new(auth.Hook), &auth.Options{
+			Ledger: &auth.Ledger{
+				Auth: auth.AuthRules{{
+					Username: auth.RString(b.cfg.MQTTUsername),
+					Password: auth.RString(b.cfg.MQTTPassword),
+					Allow:    true,
+				}},
+			},
+		})
+	} else {
+		err = b.server.AddHook(new(auth.AllowHook), nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to configure MQTT broker auth: %w", err)
+	}
+
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: b.cfg.MQTTListenAddress})
+	if err := b.server.AddListener(listener); err != nil {
+		return fmt.Errorf("failed to listen for MQTT clients: %w", err)
+	}
+
+	if err := b.server.Serve(); err != nil {
+		return fmt.Errorf("failed to start MQTT broker: %w", err)
+	}
+	b.running.Store(true)
+
+	slog.Info("Embedded MQTT broker started", "address", listener.Address())
+	return nil
+}
+
+// Check reports an error if the broker is not serving
+func (b *Broker) Check(ctx context.Context) error {
+	if !b.running.Load() {
+		return errors.New("embedded broker not running")
+	}
+	return nil
+}
+
+// Close disconnects every client and stops listening
+func (b *Broker) Close() error {
+	if !b.running.Swap(false) {
+		return nil
+	}
+	return b.server.Close()
+}
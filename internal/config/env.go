@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// loadEnv overrides settings with the environment variables that are set.
+// Values that cannot be parsed are reported instead of being ignored.
+func (c *Config) loadEnv() error {
+	env := &envLoader{}
+
+	// Backends
+	env.bool("EMBEDDED", &c.Embedded)
+	env.string("STORAGE", &c.Storage)
+	env.string("EVENT_BUS", &c.EventBus)
+	env.string("STORAGE_PATH", &c.StoragePath)
+
+	// Database
+	env.string("DB_HOST", &c.DatabaseHost)
+	env.string("DB_PORT", &c.DatabasePort)
+	env.string("DB_USER", &c.DatabaseUser)
+	env.string("DB_PASSWORD", &c.DatabasePassword)
+	env.string("DB_NAME", &c.DatabaseName)
+	env.string("DB_SSLMODE", &c.DatabaseSSLMode)
+
+	// Database timeouts
+	env.duration("DB_QUERY_TIMEOUT", &c.QueryTimeout)
+	env.duration("DB_HISTORY_QUERY_TIMEOUT", &c.HistoryQueryTimeout)
+
+	// MQTT
+	env.string("MQTT_BROKER", &c.MQTTBroker)
+	env.string("MQTT_CLIENT_ID", &c.MQTTClientID)
+	env.string("MQTT_USERNAME", &c.MQTTUsername)
+	env.string("MQTT_PASSWORD", &c.MQTTPassword)
+	env.duration("MQTT_MESSAGE_TIMEOUT", &c.MQTTMessageTimeout)
+	env.duration("MAX_CLOCK_SKEW", &c.MaxClockSkew)
+	env.bool("MQTT_EMBEDDED", &c.MQTTEmbedded)
+	env.string("MQTT_LISTEN_ADDRESS", &c.MQTTListenAddress)
+
+	// RabbitMQ
+	env.string("RABBITMQ_URL", &c.RabbitMQURL)
+	env.string("RABBITMQ_EXCHANGE", &c.RabbitMQExchange)
+	env.string("RABBITMQ_QUEUE", &c.RabbitMQQueue)
+
+	// NATS
+	env.string("NATS_URL", &c.NATSURL)
+	env.string("NATS_SUBJECT_PREFIX", &c.NATSSubjectPrefix)
+
+	// Kafka
+	env.string("KAFKA_BROKERS", &c.KafkaBrokers)
+	env.string("KAFKA_TOPIC", &c.KafkaTopic)
+
+	// MQTT events
+	env.string("MQTT_EVENTS_TOPIC", &c.MQTTEventsTopic)
+
+	// Connection retry at startup
+	env.retryPolicy("DB", &c.DBRetry)
+	env.retryPolicy("MQTT", &c.MQTTRetry)
+	env.retryPolicy("RABBITMQ", &c.RabbitMQRetry)
+	env.retryPolicy("EVENT_BUS", &c.EventBusRetry)
+
+	// Geofence
+	env.float("GEOFENCE_LATITUDE", &c.GeofenceLatitude)
+	env.float("GEOFENCE_LONGITUDE", &c.GeofenceLongitude)
+	env.float("GEOFENCE_RADIUS", &c.GeofenceRadius)
+	env.float("GEOFENCE_SPEED_LIMIT", &c.GeofenceSpeedLimit)
+
+	// Driving rules
+	env.float("SPEED_LIMIT", &c.SpeedLimit)
+	env.float("HARSH_BRAKING_THRESHOLD", &c.HarshBrakingThreshold)
+	env.float("HARSH_ACCELERATION_THRESHOLD", &c.HarshAccelerationThreshold)
+
+	// Plausibility filter
+	env.string("PLAUSIBILITY_MODE", &c.PlausibilityMode)
+	env.float("MAX_PLAUSIBLE_SPEED", &c.MaxPlausibleSpeed)
+	env.float("SERVICE_AREA_MIN_LATITUDE", &c.ServiceAreaMinLatitude)
+	env.float("SERVICE_AREA_MAX_LATITUDE", &c.ServiceAreaMaxLatitude)
+	env.float("SERVICE_AREA_MIN_LONGITUDE", &c.ServiceAreaMinLongitude)
+	env.float("SERVICE_AREA_MAX_LONGITUDE", &c.ServiceAreaMaxLongitude)
+
+	// Presence
+	env.duration("STALE_THRESHOLD", &c.StaleThreshold)
+	env.duration("OFFLINE_THRESHOLD", &c.OfflineThreshold)
+	env.duration("PRESENCE_CHECK_INTERVAL", &c.PresenceCheckInterval)
+
+	// Auth
+	env.bool("AUTH_ENABLED", &c.AuthEnabled)
+	env.string("ADMIN_API_KEY", &c.AdminAPIKey)
+	env.string("JWT_SECRET", &c.JWTSecret)
+
+	// Tenancy
+	env.string("SUPER_TENANT_ID", &c.SuperTenantID)
+
+	// Rate limiting
+	env.bool("RATE_LIMIT_ENABLED", &c.RateLimitEnabled)
+	env.float("RATE_LIMIT_RPS", &c.RateLimitRPS)
+	env.int("RATE_LIMIT_BURST", &c.RateLimitBurst)
+	env.float("HISTORY_RATE_LIMIT_RPS", &c.HistoryRateLimitRPS)
+	env.int("HISTORY_RATE_LIMIT_BURST", &c.HistoryRateLimitBurst)
+	env.float("IP_RATE_LIMIT_RPS", &c.IPRateLimitRPS)
+	env.int("IP_RATE_LIMIT_BURST", &c.IPRateLimitBurst)
+
+	// Logging
+	env.string("LOG_LEVEL", &c.LogLevel)
+	env.string("LOG_FORMAT", &c.LogFormat)
+
+	// Tracing
+	env.bool("TRACING_ENABLED", &c.TracingEnabled)
+	env.string("OTEL_EXPORTER_OTLP_ENDPOINT", &c.OTLPEndpoint)
+	env.float("TRACING_SAMPLE_RATIO", &c.TracingSampleRatio)
+	env.string("OTEL_SERVICE_NAME", &c.ServiceName)
+
+	// Health checks
+	env.duration("HEALTH_CHECK_TIMEOUT", &c.HealthCheckTimeout)
+
+	// HTTP location uploads
+	env.int("MAX_UPLOAD_BYTES", &c.MaxUploadBytes)
+	env.int("MAX_UPLOAD_RECORDS", &c.MaxUploadRecords)
+
+	// Server
+	env.string("PORT", &c.ServerPort)
+	env.duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+
+	return errors.Join(env.errs...)
+}
+
+// envLoader overwrites a setting when its variable is set and collects parse errors
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != ""
+}
+
+func (l *envLoader) fail(key, value, want string) {
+	l.errs = append(l.errs, fmt.Errorf("  %s: %q is not a valid %s", key, value, want))
+}
+
+func (l *envLoader) string(key string, dst *string) {
+	if value, ok := l.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (l *envLoader) float(key string, dst *float64) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.fail(key, value, "number")
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) int(key string, dst *int) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		l.fail(key, value, "integer")
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) bool(key string, dst *bool) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(key, value, "boolean")
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) duration(key string, dst *time.Duration) {
+	value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		l.fail(key, value, "duration such as 30s or 5m")
+		return
+	}
+	*dst = parsed
+}
+
+// retryPolicy reads <prefix>_RETRY_INITIAL_INTERVAL, <prefix>_RETRY_MAX_INTERVAL and <prefix>_RETRY_MAX_ATTEMPTS
+func (l *envLoader) retryPolicy(prefix string, dst *RetryPolicy) {
+	l.duration(prefix+"_RETRY_INITIAL_INTERVAL", &dst.InitialInterval)
+	l.duration(prefix+"_RETRY_MAX_INTERVAL", &dst.MaxInterval)
+	l.int(prefix+"_RETRY_MAX_ATTEMPTS", &dst.MaxAttempts)
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks every setting and reports all problems at once, naming each
+// setting by its environment variable
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	// Backends
+	v.oneOf("STORAGE", c.Storage, StoragePostgres, StorageTimescaleDB, StorageFile, StorageMemory)
+	v.oneOf("EVENT_BUS", c.EventBus, EventBusRabbitMQ, EventBusNATS, EventBusKafka, EventBusMQTT, EventBusMemory)
+
+	// Database
+	if c.Storage == StoragePostgres || c.Storage == StorageTimescaleDB {
+		v.port("DB_PORT", c.DatabasePort)
+		v.retryPolicy("DB", c.DBRetry)
+	}
+	if c.Storage == StorageFile {
+		v.notEmpty("STORAGE_PATH", c.StoragePath)
+	}
+	v.positiveDuration("DB_QUERY_TIMEOUT", c.QueryTimeout)
+	v.positiveDuration("DB_HISTORY_QUERY_TIMEOUT", c.HistoryQueryTimeout)
+
+	// Brokers
+	if c.MQTTEmbedded {
+		v.address("MQTT_LISTEN_ADDRESS", c.MQTTListenAddress)
+	} else {
+		v.url("MQTT_BROKER", c.MQTTBroker, "tcp", "ssl", "tls", "mqtt", "mqtts", "ws", "wss")
+	}
+	v.notEmpty("MQTT_CLIENT_ID", c.MQTTClientID)
+	v.positiveDuration("MQTT_MESSAGE_TIMEOUT", c.MQTTMessageTimeout)
+	v.positiveDuration("MAX_CLOCK_SKEW", c.MaxClockSkew)
+	v.retryPolicy("MQTT", c.MQTTRetry)
+	if c.EventBus == EventBusRabbitMQ {
+		v.url("RABBITMQ_URL", c.RabbitMQURL, "amqp", "amqps")
+		v.notEmpty("RABBITMQ_EXCHANGE", c.RabbitMQExchange)
+		v.notEmpty("RABBITMQ_QUEUE", c.RabbitMQQueue)
+		v.retryPolicy("RABBITMQ", c.RabbitMQRetry)
+	}
+
+	// Event buses
+	switch c.EventBus {
+	case EventBusNATS:
+		v.url("NATS_URL", c.NATSURL, "nats", "tls", "ws", "wss")
+		v.notEmpty("NATS_SUBJECT_PREFIX", c.NATSSubjectPrefix)
+	case EventBusKafka:
+		for _, broker := range strings.Split(c.KafkaBrokers, ",") {
+			v.address("KAFKA_BROKERS", broker)
+		}
+		v.notEmpty("KAFKA_TOPIC", c.KafkaTopic)
+	case EventBusMQTT:
+		v.notEmpty("MQTT_EVENTS_TOPIC", c.MQTTEventsTopic)
+		if strings.ContainsAny(c.MQTTEventsTopic, "+#") {
+			v.fail("MQTT_EVENTS_TOPIC", fmt.Sprintf("must not contain wildcards, got %q", c.MQTTEventsTopic))
+		}
+	}
+	if c.EventBus == EventBusNATS || c.EventBus == EventBusKafka || c.EventBus == EventBusMQTT {
+		v.retryPolicy("EVENT_BUS", c.EventBusRetry)
+	}
+
+	// Geofence and driving rules
+	v.zone(func(field string) string { return "GEOFENCE_" + strings.ToUpper(field) }, SpeedZone{
+		Latitude:   c.GeofenceLatitude,
+		Longitude:  c.GeofenceLongitude,
+		Radius:     c.GeofenceRadius,
+		SpeedLimit: c.GeofenceSpeedLimit,
+	})
+	for i, zone := range c.SpeedZones {
+		key := func(field string) string { return fmt.Sprintf("speed_zones[%d].%s", i, field) }
+		v.notEmpty(key("name"), zone.Name)
+		v.zone(key, zone)
+	}
+	v.positive("SPEED_LIMIT", c.SpeedLimit)
+	v.positive("HARSH_BRAKING_THRESHOLD", c.HarshBrakingThreshold)
+	v.positive("HARSH_ACCELERATION_THRESHOLD", c.HarshAccelerationThreshold)
+
+	// Plausibility filter
+	v.oneOf("PLAUSIBILITY_MODE", c.PlausibilityMode, PlausibilityReject, PlausibilityFlag, PlausibilityOff)
+	if c.PlausibilityMode != PlausibilityOff {
+		v.positive("MAX_PLAUSIBLE_SPEED", c.MaxPlausibleSpeed)
+		if c.HasServiceArea() {
+			v.serviceArea(c)
+		}
+	}
+
+	// Presence
+	v.positiveDuration("STALE_THRESHOLD", c.StaleThreshold)
+	v.positiveDuration("OFFLINE_THRESHOLD", c.OfflineThreshold)
+	v.positiveDuration("PRESENCE_CHECK_INTERVAL", c.PresenceCheckInterval)
+
+	// Tenancy
+	v.notEmpty("SUPER_TENANT_ID", c.SuperTenantID)
+
+	// Rate limiting
+	if c.RateLimitEnabled {
+		v.positive("RATE_LIMIT_RPS", c.RateLimitRPS)
+		v.positive("RATE_LIMIT_BURST", float64(c.RateLimitBurst))
+		v.positive("HISTORY_RATE_LIMIT_RPS", c.HistoryRateLimitRPS)
+		v.positive("HISTORY_RATE_LIMIT_BURST", float64(c.HistoryRateLimitBurst))
+		v.positive("IP_RATE_LIMIT_RPS", c.IPRateLimitRPS)
+		v.positive("IP_RATE_LIMIT_BURST", float64(c.IPRateLimitBurst))
+	}
+
+	// Logging
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		v.fail("LOG_LEVEL", fmt.Sprintf("must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+	if format := strings.ToLower(c.LogFormat); format != "json" && format != "text" {
+		v.fail("LOG_FORMAT", fmt.Sprintf("must be json or text, got %q", c.LogFormat))
+	}
+
+	// Tracing
+	if c.TracingEnabled {
+		v.url("OTEL_EXPORTER_OTLP_ENDPOINT", c.OTLPEndpoint, "http", "https")
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.fail("TRACING_SAMPLE_RATIO", fmt.Sprintf("must be between 0 and 1, got %g", c.TracingSampleRatio))
+	}
+
+	// HTTP location uploads
+	v.positive("MAX_UPLOAD_BYTES", float64(c.MaxUploadBytes))
+	v.positive("MAX_UPLOAD_RECORDS", float64(c.MaxUploadRecords))
+
+	// Server
+	v.positiveDuration("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	v.port("PORT", c.ServerPort)
+	v.positiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(key, problem string) {
+	v.errs = append(v.errs, fmt.Errorf("  %s: %s", key, problem))
+}
+
+func (v *validator) notEmpty(key, value string) {
+	if value == "" {
+		v.fail(key, "must not be empty")
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.fail(key, fmt.Sprintf("must be one of %s, got %q", strings.Join(allowed, ", "), value))
+	}
+}
+
+func (v *validator) positive(key string, value float64) {
+	if value <= 0 {
+		v.fail(key, fmt.Sprintf("must be greater than 0, got %g", value))
+	}
+}
+
+func (v *validator) positiveDuration(key string, value time.Duration) {
+	if value <= 0 {
+		v.fail(key, fmt.Sprintf("must be greater than 0, got %s", value))
+	}
+}
+
+func (v *validator) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.fail(key, fmt.Sprintf("must be a port number between 1 and 65535, got %q", value))
+	}
+}
+
+func (v *validator) url(key, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		v.fail(key, fmt.Sprintf("is not a valid URL: %v", err))
+		return
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		v.fail(key, fmt.Sprintf("must be a URL with scheme %s, got %q", strings.Join(schemes, ", "), value))
+		return
+	}
+	if u.Host == "" {
+		v.fail(key, fmt.Sprintf("must include a host, got %q", value))
+	}
+}
+
+// address checks a host:port listen address; the host may be empty
+func (v *validator) address(key, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.fail(key, fmt.Sprintf("must be a host:port address, got %q", value))
+		return
+	}
+	v.port(key, port)
+}
+
+// zone checks a circular zone; key names each field of the zone
+func (v *validator) zone(key func(field string) string, zone SpeedZone) {
+	if zone.Latitude < -90 || zone.Latitude > 90 {
+		v.fail(key("latitude"), fmt.Sprintf("must be between -90 and 90, got %g", zone.Latitude))
+	}
+	if zone.Longitude < -180 || zone.Longitude > 180 {
+		v.fail(key("longitude"), fmt.Sprintf("must be between -180 and 180, got %g", zone.Longitude))
+	}
+	v.positive(key("radius"), zone.Radius)
+	v.positive(key("speed_limit"), zone.SpeedLimit)
+}
+
+// serviceArea checks that the service area bounds are in range and ordered
+func (v *validator) serviceArea(c *Config) {
+	if c.ServiceAreaMinLatitude < -90 || c.ServiceAreaMaxLatitude > 90 || c.ServiceAreaMinLatitude >= c.ServiceAreaMaxLatitude {
+		v.fail("SERVICE_AREA_MIN_LATITUDE", fmt.Sprintf("must be less than SERVICE_AREA_MAX_LATITUDE, both within -90 and 90, got %g and %g",
+			c.ServiceAreaMinLatitude, c.ServiceAreaMaxLatitude))
+	}
+	if c.ServiceAreaMinLongitude < -180 || c.ServiceAreaMaxLongitude > 180 || c.ServiceAreaMinLongitude >= c.ServiceAreaMaxLongitude {
+		v.fail("SERVICE_AREA_MIN_LONGITUDE", fmt.Sprintf("must be less than SERVICE_AREA_MAX_LONGITUDE, both within -180 and 180, got %g and %g",
+			c.ServiceAreaMinLongitude, c.ServiceAreaMaxLongitude))
+	}
+}
+
+func (v *validator) retryPolicy(prefix string, policy RetryPolicy) {
+	v.positiveDuration(prefix+"_RETRY_INITIAL_INTERVAL", policy.InitialInterval)
+	if policy.MaxInterval < policy.InitialInterval {
+		v.fail(prefix+"_RETRY_MAX_INTERVAL", fmt.Sprintf("must not be less than %s_RETRY_INITIAL_INTERVAL, got %s", prefix, policy.MaxInterval))
+	}
+	if policy.MaxAttempts < 0 {
+		v.fail(prefix+"_RETRY_MAX_ATTEMPTS", fmt.Sprintf("must not be negative, got %d", policy.MaxAttempts))
+	}
+}
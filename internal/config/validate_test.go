@@ -0,0 +1,122 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// wantKeys are the settings the error must name, none if the configuration is valid
+		wantKeys []string
+	}{
+		{
+			name:   "defaults",
+			modify: func(c *Config) {},
+		},
+		{
+			name:     "unknown storage",
+			modify:   func(c *Config) { c.Storage = "mysql" },
+			wantKeys: []string{"STORAGE"},
+		},
+		{
+			name: "file storage without a path",
+			modify: func(c *Config) {
+				c.Storage = StorageFile
+				c.StoragePath = ""
+			},
+			wantKeys: []string{"STORAGE_PATH"},
+		},
+		{
+			name: "MQTT event topic with a wildcard",
+			modify: func(c *Config) {
+				c.EventBus = EventBusMQTT
+				c.MQTTEventsTopic = "/fleet/vehicle/+/events"
+			},
+			wantKeys: []string{"MQTT_EVENTS_TOPIC"},
+		},
+		{
+			name:     "non-positive clock skew",
+			modify:   func(c *Config) { c.MaxClockSkew = 0 },
+			wantKeys: []string{"MAX_CLOCK_SKEW"},
+		},
+		{
+			name: "non-positive upload limits",
+			modify: func(c *Config) {
+				c.MaxUploadBytes = 0
+				c.MaxUploadRecords = -1
+			},
+			wantKeys: []string{"MAX_UPLOAD_BYTES", "MAX_UPLOAD_RECORDS"},
+		},
+		{
+			name:     "unknown plausibility mode",
+			modify:   func(c *Config) { c.PlausibilityMode = "drop" },
+			wantKeys: []string{"PLAUSIBILITY_MODE"},
+		},
+		{
+			name: "inverted service area",
+			modify: func(c *Config) {
+				c.ServiceAreaMinLatitude, c.ServiceAreaMaxLatitude = -5.9, -6.9
+			},
+			wantKeys: []string{"SERVICE_AREA_MIN_LATITUDE"},
+		},
+		{
+			name: "service area ignored when the filter is off",
+			modify: func(c *Config) {
+				c.PlausibilityMode = PlausibilityOff
+				c.MaxPlausibleSpeed = 0
+				c.ServiceAreaMinLatitude, c.ServiceAreaMaxLatitude = -5.9, -6.9
+			},
+		},
+		{
+			name: "rate limits checked only when enabled",
+			modify: func(c *Config) {
+				c.RateLimitEnabled = false
+				c.IPRateLimitRPS = 0
+			},
+		},
+		{
+			name: "zero IP rate limit",
+			modify: func(c *Config) {
+				c.RateLimitEnabled = true
+				c.IPRateLimitRPS = 0
+			},
+			wantKeys: []string{"IP_RATE_LIMIT_RPS"},
+		},
+		{
+			name: "every problem reported at once",
+			modify: func(c *Config) {
+				c.LogLevel = "verbose"
+				c.TracingSampleRatio = 2
+				c.ShutdownTimeout = -time.Second
+			},
+			wantKeys: []string{"LOG_LEVEL", "TRACING_SAMPLE_RATIO", "SHUTDOWN_TIMEOUT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.wantKeys) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want an error naming %v", tt.wantKeys)
+			}
+			for _, key := range tt.wantKeys {
+				if !strings.Contains(err.Error(), key+":") {
+					t.Errorf("Validate() = %q, want it to name %s", err, key)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	_ "github.com/lib/pq"
 	"transjakarta-fleet/internal/config"
 )
 
+// NewPostgresDB opens a connection pool without contacting the server, so it
+// succeeds while PostgreSQL is still starting; use Connect to wait for it.
 func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
 	connStr := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -25,12 +28,17 @@ func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("error connecting to database: %w", err)
+	return db, nil
+}
+
+// Connect checks that the server is reachable
+func Connect(ctx context.Context, db *sql.DB, cfg *config.Config) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL database")
-	return db, nil
+	slog.Info("Connected to PostgreSQL", "host", cfg.DatabaseHost, "database", cfg.DatabaseName)
+	return nil
 }
 
 func RunMigrations(db *sql.DB) error {
@@ -41,12 +49,38 @@ func RunMigrations(db *sql.DB) error {
 		latitude DOUBLE PRECISION NOT NULL,
 		longitude DOUBLE PRECISION NOT NULL,
 		timestamp BIGINT NOT NULL,
+		speed DOUBLE PRECISION,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	ALTER TABLE vehicle_locations ADD COLUMN IF NOT EXISTS speed DOUBLE PRECISION;
+	ALTER TABLE vehicle_locations ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
+	ALTER TABLE vehicle_locations ADD COLUMN IF NOT EXISTS late BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE vehicle_locations ADD COLUMN IF NOT EXISTS implausible VARCHAR(32);
+
+	CREATE TABLE IF NOT EXISTS vehicles (
+		vehicle_id VARCHAR(50) PRIMARY KEY,
+		operator_id VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_vehicle_id ON vehicle_locations(vehicle_id);
 	CREATE INDEX IF NOT EXISTS idx_timestamp ON vehicle_locations(timestamp);
 	CREATE INDEX IF NOT EXISTS idx_vehicle_timestamp ON vehicle_locations(vehicle_id, timestamp DESC);
+	CREATE INDEX IF NOT EXISTS idx_operator_timestamp ON vehicle_locations(operator_id, timestamp);
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		role VARCHAR(20) NOT NULL,
+		prefix VARCHAR(12) NOT NULL,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+
+	ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
 	`
 
 	_, err := db.Exec(query)
@@ -54,6 +88,6 @@ func RunMigrations(db *sql.DB) error {
 		return fmt.Errorf("error running migrations: %w", err)
 	}
 
-	log.Println("Database migrations completed successfully")
+	slog.Info("Database migrations completed")
 	return nil
 }
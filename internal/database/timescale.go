@@ -0,0 +1,88 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// timescaleMigrations turn vehicle_locations into a hypertable and maintain an
+// hourly continuous aggregate per vehicle. They run one by one because
+// TimescaleDB refuses to create continuous aggregates inside a transaction.
+var timescaleMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS timescaledb`,
+
+	// Unique indexes of a hypertable must include the partitioning column,
+	// and timestamp is in Unix seconds so chunks span one day
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'vehicle_locations'
+		) THEN
+			ALTER TABLE vehicle_locations DROP CONSTRAINT IF EXISTS vehicle_locations_pkey;
+			ALTER TABLE vehicle_locations ADD PRIMARY KEY (id, timestamp);
+			PERFORM create_hypertable('vehicle_locations', 'timestamp',
+				chunk_time_interval => 86400::BIGINT, migrate_data => true);
+		END IF;
+	END
+	$$`,
+
+	// Refresh policies on an integer time column need to know the current time
+	`CREATE OR REPLACE FUNCTION unix_now() RETURNS BIGINT
+	LANGUAGE SQL STABLE AS $$ SELECT EXTRACT(EPOCH FROM now())::BIGINT $$`,
+	`SELECT set_integer_now_func('vehicle_locations', 'unix_now', replace_if_exists => true)`,
+
+	// Aggregates created before implausible locations were left out are rebuilt.
+	// The refresh policy materializes recent buckets again, and real-time
+	// aggregation covers the rest meanwhile.
+	`DO $$
+	BEGIN
+		IF EXISTS (
+			SELECT 1 FROM timescaledb_information.continuous_aggregates
+			WHERE view_name = 'vehicle_locations_hourly' AND view_definition NOT LIKE '%implausible%'
+		) THEN
+			DROP MATERIALIZED VIEW vehicle_locations_hourly;
+		END IF;
+	END
+	$$`,
+
+	// Real-time aggregation adds the rows not materialized yet, so the view is never stale
+	`CREATE MATERIALIZED VIEW IF NOT EXISTS vehicle_locations_hourly
+	WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+	SELECT
+		vehicle_id,
+		operator_id,
+		time_bucket(3600::BIGINT, timestamp) AS bucket,
+		count(*) AS points,
+		max(timestamp) AS last_timestamp,
+		last(latitude, timestamp) AS latitude,
+		last(longitude, timestamp) AS longitude,
+		last(speed, timestamp) AS speed,
+		max(speed) AS max_speed
+	FROM vehicle_locations
+	WHERE implausible IS NULL
+	GROUP BY vehicle_id, operator_id, bucket
+	WITH NO DATA`,
+	`SELECT add_continuous_aggregate_policy('vehicle_locations_hourly',
+		start_offset => 259200::BIGINT,
+		end_offset => 3600::BIGINT,
+		schedule_interval => INTERVAL '15 minutes',
+		if_not_exists => true)`,
+}
+
+// RunTimescaleMigrations runs the regular migrations, then converts the
+// location history to a TimescaleDB hypertable
+func RunTimescaleMigrations(db *sql.DB) error {
+	if err := RunMigrations(db); err != nil {
+		return err
+	}
+
+	for _, query := range timescaleMigrations {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("error running TimescaleDB migrations: %w", err)
+		}
+	}
+
+	slog.Info("TimescaleDB migrations completed")
+	return nil
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Bus delivers published events to in-process subscribers. Handlers run
+// synchronously in the publisher's goroutine, so they should return quickly.
+type Bus struct {
+	mu       sync.RWMutex
+	geofence []func(context.Context, *models.GeofenceEvent)
+	driving  []func(context.Context, *models.DrivingEvent)
+	presence []func(context.Context, *models.PresenceEvent)
+}
+
+var _ Publisher = (*Bus)(nil)
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// OnGeofenceEvent subscribes fn to geofence events
+func (b *Bus) OnGeofenceEvent(fn func(context.Context, *models.GeofenceEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.geofence = append(b.geofence, fn)
+}
+
+// OnDrivingEvent subscribes fn to driving events
+func (b *Bus) OnDrivingEvent(fn func(context.Context, *models.DrivingEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.driving = append(b.driving, fn)
+}
+
+// OnPresenceEvent subscribes fn to presence events
+func (b *Bus) OnPresenceEvent(fn func(context.Context, *models.PresenceEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.presence = append(b.presence, fn)
+}
+
+func (b *Bus) PublishGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) error {
+	b.mu.RLock()
+	handlers := b.geofence
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, event)
+	}
+	return nil
+}
+
+func (b *Bus) PublishDrivingEvent(ctx context.Context, event *models.DrivingEvent) error {
+	b.mu.RLock()
+	handlers := b.driving
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, event)
+	}
+	return nil
+}
+
+func (b *Bus) PublishPresenceEvent(ctx context.Context, event *models.PresenceEvent) error {
+	b.mu.RLock()
+	handlers := b.presence
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, event)
+	}
+	return nil
+}
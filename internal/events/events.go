@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"strings"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Publisher delivers vehicle events to downstream consumers
+type Publisher interface {
+	PublishGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) error
+	PublishDrivingEvent(ctx context.Context, event *models.DrivingEvent) error
+	PublishPresenceEvent(ctx context.Context, event *models.PresenceEvent) error
+}
+
+// Backend is a Publisher backed by an external broker. Publishing fails until Connect succeeds.
+type Backend interface {
+	Publisher
+	Connect() error
+	Check(ctx context.Context) error
+	Close() error
+}
+
+// GeofenceKey is geofence.entry. Every backend routes events by these keys,
+// as AMQP routing keys, NATS subject suffixes or Kafka headers.
+func GeofenceKey(event *models.GeofenceEvent) string {
+	return "geofence.entry"
+}
+
+// DrivingKey is driving.<event>, e.g. driving.speeding
+func DrivingKey(event *models.DrivingEvent) string {
+	return "driving." + event.Event
+}
+
+// PresenceKey is vehicle.online or vehicle.offline
+func PresenceKey(event *models.PresenceEvent) string {
+	return strings.Replace(event.Event, "vehicle_", "vehicle.", 1)
+}
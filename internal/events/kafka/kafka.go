@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+)
+
+var errNotConnected = errors.New("not connected to Kafka")
+
+// Transport produces events to a single Kafka topic, keyed by vehicle ID so the
+// events of a vehicle stay ordered. The routing key is sent in the event-type header.
+// Any broker speaking the Kafka protocol works, e.g. Redpanda.
+type Transport struct {
+	cfg     *config.Config
+	brokers []string
+
+	mu     sync.RWMutex
+	writer *kafka.Writer
+}
+
+var _ events.Transport = (*Transport)(nil)
+
+// New returns an unconnected Kafka event bus
+func New(cfg *config.Config) *events.Remote {
+	return events.NewRemote("kafka", &Transport{
+		cfg:     cfg,
+		brokers: strings.Split(cfg.KafkaBrokers, ","),
+	})
+}
+
+// Connect checks that a broker is reachable; the writer itself connects lazily
+func (t *Transport) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := t.dial(ctx); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.writer = &kafka.Writer{
+		Addr:                   kafka.TCP(t.brokers...),
+		Topic:                  t.cfg.KafkaTopic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		// Events are written one at a time; do not hold them back to fill a batch
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	t.mu.Unlock()
+
+	slog.Info("Connected to Kafka", "brokers", t.cfg.KafkaBrokers, "topic", t.cfg.KafkaTopic)
+	return nil
+}
+
+// dial opens and closes a connection to the first reachable broker
+func (t *Transport) dial(ctx context.Context) error {
+	var errs []error
+	for _, broker := range t.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("failed to connect to Kafka: %w", errors.Join(errs...))
+}
+
+func (t *Transport) currentWriter() *kafka.Writer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.writer
+}
+
+func (t *Transport) Send(ctx context.Context, key, vehicleID string, body []byte) error {
+	writer := t.currentWriter()
+	if writer == nil {
+		return errNotConnected
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(vehicleID),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "event-type", Value: []byte(key)},
+			{Key: "content-type", Value: []byte("application/json")},
+		},
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&msg})
+
+	return writer.WriteMessages(ctx, msg)
+}
+
+// Check reports an error if no broker can be reached
+func (t *Transport) Check(ctx context.Context) error {
+	if t.currentWriter() == nil {
+		return errNotConnected
+	}
+	return t.dial(ctx)
+}
+
+// Close flushes pending writes
+func (t *Transport) Close() error {
+	writer := t.currentWriter()
+	if writer == nil {
+		return nil
+	}
+	return writer.Close()
+}
+
+// headerCarrier adapts Kafka message headers for trace context propagation
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.msg.Headers = append(c.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Memory records published events in process memory instead of sending them anywhere
+type Memory struct {
+	mu       sync.Mutex
+	geofence []*models.GeofenceEvent
+	driving  []*models.DrivingEvent
+	presence []*models.PresenceEvent
+}
+
+var _ Publisher = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) PublishGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.geofence = append(m.geofence, event)
+	return nil
+}
+
+func (m *Memory) PublishDrivingEvent(ctx context.Context, event *models.DrivingEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.driving = append(m.driving, event)
+	return nil
+}
+
+func (m *Memory) PublishPresenceEvent(ctx context.Context, event *models.PresenceEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presence = append(m.presence, event)
+	return nil
+}
+
+// GeofenceEvents returns the geofence events published so far, oldest first
+func (m *Memory) GeofenceEvents() []*models.GeofenceEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*models.GeofenceEvent(nil), m.geofence...)
+}
+
+// DrivingEvents returns the driving events published so far, oldest first
+func (m *Memory) DrivingEvents() []*models.DrivingEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*models.DrivingEvent(nil), m.driving...)
+}
+
+// PresenceEvents returns the presence events published so far, oldest first
+func (m *Memory) PresenceEvents() []*models.PresenceEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*models.PresenceEvent(nil), m.presence...)
+}
@@ -0,0 +1,206 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"go.opentelemetry.io/otel"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/ingest"
+)
+
+var errNotConnected = errors.New("not connected to MQTT broker")
+
+// connectTimeout bounds how long Connect waits for the broker
+const connectTimeout = 10 * time.Second
+
+// Transport republishes events onto the MQTT broker the locations arrive on,
+// under a per-vehicle topic such as /fleet/vehicle/B1234/events. It uses its
+// own connection so events can still be published while the location
+// subscriber shuts down.
+type Transport struct {
+	cfg *config.Config
+
+	mu   sync.Mutex // guards conn
+	conn *autopaho.ConnectionManager
+
+	connected  atomic.Bool
+	connectErr atomic.Pointer[error] // last failed connection attempt
+}
+
+var _ events.Transport = (*Transport)(nil)
+
+// New returns an unconnected MQTT event bus
+func New(cfg *config.Config) *events.Remote {
+	return events.NewRemote("mqtt", &Transport{cfg: cfg})
+}
+
+// Connect starts connecting to the broker and waits for the first connection.
+// The connection is kept up in the background from then on, so a failed
+// Connect can simply be called again.
+func (t *Transport) Connect() error {
+	t.mu.Lock()
+	if t.conn == nil {
+		conn, err := t.newConnection()
+		if err != nil {
+			t.mu.Unlock()
+			return err
+		}
+		t.conn = conn
+	}
+	conn := t.conn
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	if err := conn.AwaitConnection(ctx); err != nil {
+		if last := t.connectErr.Load(); last != nil {
+			err = *last
+		}
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	slog.Info("Connected to MQTT broker for events", "broker", t.cfg.MQTTBroker, "topic", t.cfg.MQTTEventsTopic)
+	return nil
+}
+
+func (t *Transport) newConnection() (*autopaho.ConnectionManager, error) {
+	broker, err := url.Parse(t.cfg.MQTTBroker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{broker},
+		KeepAlive:                     60,
+		CleanStartOnInitialConnection: true,
+		ConnectRetryDelay:             5 * time.Second,
+		ConnectTimeout:                connectTimeout,
+		OnConnectionUp: func(*autopaho.ConnectionManager, *paho.Connack) {
+			t.connected.Store(true)
+		},
+		OnConnectError: func(err error) {
+			t.connectErr.Store(&err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:      t.cfg.MQTTClientID + "-events",
+			OnClientError: t.onConnectionLost,
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				t.onConnectionLost(fmt.Errorf("disconnected by broker, reason code %d", d.ReasonCode))
+			},
+		},
+	}
+	if t.cfg.MQTTUsername != "" {
+		cfg.ConnectUsername = t.cfg.MQTTUsername
+		cfg.ConnectPassword = []byte(t.cfg.MQTTPassword)
+	}
+
+	// Close cancels the connection, not this context
+	conn, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MQTT connection: %w", err)
+	}
+	return conn, nil
+}
+
+func (t *Transport) onConnectionLost(err error) {
+	if t.connected.Swap(false) {
+		slog.Warn("MQTT event publisher connection lost", "error", err)
+	}
+}
+
+func (t *Transport) currentConn() *autopaho.ConnectionManager {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conn
+}
+
+// Send publishes with QoS 1. The event type and the trace context travel in
+// MQTT v5 user properties, like the headers of the other buses.
+func (t *Transport) Send(ctx context.Context, key, vehicleID string, body []byte) error {
+	conn := t.currentConn()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	topic, err := eventTopic(t.cfg.MQTTEventsTopic, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	properties := &paho.PublishProperties{ContentType: "application/json"}
+	properties.User.Add("event-type", key)
+	otel.GetTextMapPropagator().Inject(ctx, userPropertiesCarrier{&properties.User})
+
+	_, err = conn.Publish(ctx, &paho.Publish{
+		Topic:      topic,
+		QoS:        1,
+		Payload:    body,
+		Properties: properties,
+	})
+	return err
+}
+
+// eventTopic fills the vehicle into pattern. A vehicle ID that is not a valid
+// topic level would publish to another vehicle's topic, or to an invalid one.
+func eventTopic(pattern, vehicleID string) (string, error) {
+	if err := ingest.ValidateVehicleID(vehicleID); err != nil {
+		return "", fmt.Errorf("cannot publish event to MQTT: %w", err)
+	}
+	return strings.ReplaceAll(pattern, "{vehicle_id}", vehicleID), nil
+}
+
+// Check reports an error if the broker connection is down
+func (t *Transport) Check(ctx context.Context) error {
+	if t.currentConn() == nil || !t.connected.Load() {
+		return errNotConnected
+	}
+	return nil
+}
+
+func (t *Transport) Close() error {
+	conn := t.currentConn()
+	if conn == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	t.connected.Store(false)
+	if err := conn.Disconnect(ctx); err != nil {
+		return fmt.Errorf("failed to disconnect from MQTT broker: %w", err)
+	}
+	return nil
+}
+
+// userPropertiesCarrier adapts MQTT v5 user properties for trace context propagation
+type userPropertiesCarrier struct {
+	properties *paho.UserProperties
+}
+
+func (c userPropertiesCarrier) Get(key string) string {
+	return c.properties.Get(key)
+}
+
+func (c userPropertiesCarrier) Set(key, value string) {
+	c.properties.Add(key, value)
+}
+
+func (c userPropertiesCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.properties))
+	for _, p := range *c.properties {
+		keys = append(keys, p.Key)
+	}
+	return keys
+}
@@ -0,0 +1,98 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+)
+
+var errNotConnected = errors.New("not connected to NATS")
+
+// Transport publishes events to NATS subjects named <prefix>.<routing key>,
+// e.g. fleet.events.geofence.entry
+type Transport struct {
+	cfg *config.Config
+
+	mu   sync.RWMutex
+	conn *nats.Conn
+}
+
+var _ events.Transport = (*Transport)(nil)
+
+// New returns an unconnected NATS event bus
+func New(cfg *config.Config) *events.Remote {
+	return events.NewRemote("nats", &Transport{cfg: cfg})
+}
+
+func (t *Transport) Connect() error {
+	conn, err := nats.Connect(t.cfg.NATSURL,
+		nats.Name(t.cfg.ServiceName),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("NATS connection lost", "error", err)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			slog.Info("Reconnected to NATS", "url", conn.ConnectedUrlRedacted())
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	t.mu.Lock()
+	t.conn = conn
+	t.mu.Unlock()
+
+	slog.Info("Connected to NATS", "url", conn.ConnectedUrlRedacted(), "subject_prefix", t.cfg.NATSSubjectPrefix)
+	return nil
+}
+
+func (t *Transport) currentConn() *nats.Conn {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.conn
+}
+
+func (t *Transport) Send(ctx context.Context, key, vehicleID string, body []byte) error {
+	conn := t.currentConn()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	msg := nats.NewMsg(t.cfg.NATSSubjectPrefix + "." + key)
+	msg.Data = body
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Header.Set("Vehicle-Id", vehicleID)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+
+	return conn.PublishMsg(msg)
+}
+
+// Check reports an error unless the connection is up
+func (t *Transport) Check(ctx context.Context) error {
+	conn := t.currentConn()
+	if conn == nil {
+		return errNotConnected
+	}
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("connection %s", status)
+	}
+	return nil
+}
+
+// Close flushes buffered events and closes the connection
+func (t *Transport) Close() error {
+	conn := t.currentConn()
+	if conn == nil {
+		return nil
+	}
+	return conn.Drain()
+}
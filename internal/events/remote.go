@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/tracing"
+)
+
+// Transport sends encoded events to an external broker
+type Transport interface {
+	Connect() error
+	Check(ctx context.Context) error
+	Close() error
+
+	// Send delivers body under key. ctx carries the producer span, which the
+	// transport should propagate in message headers where the protocol has them.
+	Send(ctx context.Context, key, vehicleID string, body []byte) error
+}
+
+// Remote publishes events as JSON through a Transport, tracing and counting each one
+type Remote struct {
+	Transport
+	system string
+}
+
+var _ Backend = (*Remote)(nil)
+
+// NewRemote returns a Backend sending through t; system names the broker in spans and metrics
+func NewRemote(system string, t Transport) *Remote {
+	return &Remote{
+		Transport: t,
+		system:    system,
+	}
+}
+
+func (r *Remote) PublishGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) error {
+	if err := r.publish(ctx, GeofenceKey(event), event.VehicleID, event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published geofence event", "vehicle_id", event.VehicleID, "event", event.Event, "bus", r.system)
+	return nil
+}
+
+func (r *Remote) PublishDrivingEvent(ctx context.Context, event *models.DrivingEvent) error {
+	if err := r.publish(ctx, DrivingKey(event), event.VehicleID, event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published driving event", "vehicle_id", event.VehicleID, "event", event.Event, "speed_kmh", event.SpeedKmh, "bus", r.system)
+	return nil
+}
+
+func (r *Remote) PublishPresenceEvent(ctx context.Context, event *models.PresenceEvent) error {
+	if err := r.publish(ctx, PresenceKey(event), event.VehicleID, event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published presence event", "vehicle_id", event.VehicleID, "event", event.Event, "bus", r.system)
+	return nil
+}
+
+func (r *Remote) publish(ctx context.Context, key, vehicleID string, event any) (err error) {
+	ctx, span := tracing.Start(ctx, key+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(r.system),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(key),
+		),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+		metrics.ObserveEventPublish(r.system, key, err)
+	}()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := r.Send(ctx, key, vehicleID, body); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
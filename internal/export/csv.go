@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// csvWriter writes one row per location with a header row
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin() error {
+	return c.w.Write([]string{"vehicle_id", "latitude", "longitude", "timestamp", "speed"})
+}
+
+func (c *csvWriter) Write(location *models.VehicleLocation) error {
+	speed := ""
+	if location.Speed != nil {
+		speed = strconv.FormatFloat(*location.Speed, 'f', -1, 64)
+	}
+
+	return c.w.Write([]string{
+		location.VehicleID,
+		formatCoordinate(location.Latitude),
+		formatCoordinate(location.Longitude),
+		strconv.FormatInt(location.Timestamp, 10),
+		speed,
+	})
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Supported export formats
+const (
+	FormatGeoJSON = "geojson"
+	FormatGPX     = "gpx"
+	FormatCSV     = "csv"
+	FormatKML     = "kml"
+)
+
+// Writer streams a vehicle track in a specific file format.
+// Begin must be called once before the first Write and End once after the last.
+type Writer interface {
+	Begin() error
+	Write(location *models.VehicleLocation) error
+	End() error
+}
+
+// NewWriter returns a Writer for the given format that writes the track of vehicleID to w.
+// Locations flagged implausible are left out, as none of the formats can mark them.
+func NewWriter(format string, w io.Writer, vehicleID string) (Writer, error) {
+	var writer Writer
+	switch format {
+	case FormatGeoJSON:
+		writer = newGeoJSONWriter(w, vehicleID)
+	case FormatGPX:
+		writer = newGPXWriter(w, vehicleID)
+	case FormatCSV:
+		writer = newCSVWriter(w)
+	case FormatKML:
+		writer = newKMLWriter(w, vehicleID)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+	return plausibleWriter{writer}, nil
+}
+
+// plausibleWriter passes only locations the plausibility filter did not flag
+type plausibleWriter struct {
+	Writer
+}
+
+func (p plausibleWriter) Write(location *models.VehicleLocation) error {
+	if location.Implausible != "" {
+		return nil
+	}
+	return p.Writer.Write(location)
+}
+
+// formatCoordinate formats a latitude or longitude with as many digits as it has
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatGeoJSON:
+		return "application/geo+json"
+	case FormatGPX:
+		return "application/gpx+xml"
+	case FormatCSV:
+		return "text/csv"
+	case FormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// FormatForContentType returns the format matching a MIME type, or an empty string
+func FormatForContentType(contentType string) string {
+	for _, format := range []string{FormatGeoJSON, FormatGPX, FormatCSV, FormatKML} {
+		if ContentType(format) == contentType {
+			return format
+		}
+	}
+	return ""
+}
@@ -0,0 +1,321 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"transjakarta-fleet/internal/models"
+)
+
+func track(n int) []*models.VehicleLocation {
+	speed := 30.5
+	locations := make([]*models.VehicleLocation, n)
+	for i := range locations {
+		locations[i] = &models.VehicleLocation{
+			VehicleID: "B1",
+			Latitude:  -6.2 + float64(i)*0.001,
+			Longitude: 106.8,
+			Timestamp: 1700000000 + int64(i)*10,
+		}
+		if i%2 == 0 {
+			locations[i].Speed = &speed
+		}
+	}
+	return locations
+}
+
+func write(t *testing.T, format, vehicleID string, locations []*models.VehicleLocation) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf, vehicleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range locations {
+		if err := writer.Write(location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.End(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGeoJSON(t *testing.T) {
+	type geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string         `json:"type"`
+		Geometry   geometry       `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	}
+	type collection struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}
+
+	tests := []struct {
+		name            string
+		points          int
+		wantGeometry    string // empty for no feature
+		wantCoordinates string
+		wantStart       float64
+		wantEnd         float64
+	}{
+		{
+			name:   "empty track",
+			points: 0,
+		},
+		{
+			// A LineString needs at least two positions
+			name:            "single location",
+			points:          1,
+			wantGeometry:    "Point",
+			wantCoordinates: `[106.8,-6.2]`,
+			wantStart:       1700000000,
+			wantEnd:         1700000000,
+		},
+		{
+			name:            "two locations",
+			points:          2,
+			wantGeometry:    "LineString",
+			wantCoordinates: `[[106.8,-6.2],[106.8,-6.199]]`,
+			wantStart:       1700000000,
+			wantEnd:         1700000010,
+		},
+		{
+			name:            "longer track",
+			points:          3,
+			wantGeometry:    "LineString",
+			wantCoordinates: `[[106.8,-6.2],[106.8,-6.199],[106.8,-6.198]]`,
+			wantStart:       1700000000,
+			wantEnd:         1700000020,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := write(t, FormatGeoJSON, "B1", track(tt.points))
+
+			var got collection
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("invalid JSON %s: %v", out, err)
+			}
+			if got.Type != "FeatureCollection" {
+				t.Errorf("type = %q, want FeatureCollection", got.Type)
+			}
+
+			if tt.wantGeometry == "" {
+				if len(got.Features) != 0 {
+					t.Errorf("features = %+v, want none", got.Features)
+				}
+				return
+			}
+			if len(got.Features) != 1 {
+				t.Fatalf("got %d features, want 1", len(got.Features))
+			}
+
+			f := got.Features[0]
+			if f.Type != "Feature" || f.Geometry.Type != tt.wantGeometry {
+				t.Errorf("feature %s with %s geometry, want Feature with %s", f.Type, f.Geometry.Type, tt.wantGeometry)
+			}
+			if string(f.Geometry.Coordinates) != tt.wantCoordinates {
+				t.Errorf("coordinates = %s, want %s", f.Geometry.Coordinates, tt.wantCoordinates)
+			}
+			wantProperties := map[string]any{
+				"vehicle_id": "B1",
+				"points":     float64(tt.points),
+				"start_time": tt.wantStart,
+				"end_time":   tt.wantEnd,
+			}
+			if !reflect.DeepEqual(f.Properties, wantProperties) {
+				t.Errorf("properties = %v, want %v", f.Properties, wantProperties)
+			}
+		})
+	}
+}
+
+func TestCSV(t *testing.T) {
+	out := write(t, FormatCSV, "B1", track(2))
+
+	rows, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"vehicle_id", "latitude", "longitude", "timestamp", "speed"},
+		{"B1", "-6.2", "106.8", "1700000000", "30.5"},
+		{"B1", "-6.199", "106.8", "1700000010", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestGPX(t *testing.T) {
+	var doc struct {
+		XMLName xml.Name `xml:"gpx"`
+		Name    string   `xml:"trk>name"`
+		Points  []struct {
+			Lat  float64 `xml:"lat,attr"`
+			Lon  float64 `xml:"lon,attr"`
+			Time string  `xml:"time"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+
+	out := write(t, FormatGPX, "B<1>&", track(2))
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid XML %s: %v", out, err)
+	}
+	if doc.Name != "B<1>&" {
+		t.Errorf("name = %q, want the vehicle ID", doc.Name)
+	}
+	if len(doc.Points) != 2 {
+		t.Fatalf("got %d points, want 2", len(doc.Points))
+	}
+	if p := doc.Points[1]; p.Lat != -6.199 || p.Lon != 106.8 || p.Time != "2023-11-14T22:13:30Z" {
+		t.Errorf("second point = %+v", p)
+	}
+}
+
+func TestKML(t *testing.T) {
+	type placemark struct {
+		Name       string `xml:"name"`
+		LineString *struct {
+			Coordinates string `xml:"coordinates"`
+		} `xml:"LineString"`
+		Point *struct {
+			Coordinates string `xml:"coordinates"`
+		} `xml:"Point"`
+	}
+	type document struct {
+		XMLName    xml.Name    `xml:"kml"`
+		Placemarks []placemark `xml:"Document>Placemark"`
+	}
+
+	tests := []struct {
+		name            string
+		points          int
+		wantGeometry    string // empty for no placemark
+		wantCoordinates []string
+	}{
+		{
+			name:   "empty track",
+			points: 0,
+		},
+		{
+			// A LineString needs at least two coordinates
+			name:            "single location",
+			points:          1,
+			wantGeometry:    "Point",
+			wantCoordinates: []string{"106.8,-6.2,0"},
+		},
+		{
+			name:            "two locations",
+			points:          2,
+			wantGeometry:    "LineString",
+			wantCoordinates: []string{"106.8,-6.2,0", "106.8,-6.199,0"},
+		},
+		{
+			name:            "longer track",
+			points:          3,
+			wantGeometry:    "LineString",
+			wantCoordinates: []string{"106.8,-6.2,0", "106.8,-6.199,0", "106.8,-6.198,0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := write(t, FormatKML, "B<1>&", track(tt.points))
+			var doc document
+			if err := xml.Unmarshal(out, &doc); err != nil {
+				t.Fatalf("invalid XML %s: %v", out, err)
+			}
+
+			if tt.wantGeometry == "" {
+				if len(doc.Placemarks) != 0 {
+					t.Errorf("placemarks = %+v, want none", doc.Placemarks)
+				}
+				return
+			}
+			if len(doc.Placemarks) != 1 {
+				t.Fatalf("got %d placemarks, want 1: %s", len(doc.Placemarks), out)
+			}
+
+			p := doc.Placemarks[0]
+			if p.Name != "B<1>&" {
+				t.Errorf("name = %q, want the vehicle ID", p.Name)
+			}
+			var coordinates string
+			switch {
+			case tt.wantGeometry == "Point" && p.Point != nil && p.LineString == nil:
+				coordinates = p.Point.Coordinates
+			case tt.wantGeometry == "LineString" && p.LineString != nil && p.Point == nil:
+				coordinates = p.LineString.Coordinates
+			default:
+				t.Fatalf("placemark %s, want a %s", out, tt.wantGeometry)
+			}
+			if got := strings.Fields(coordinates); !reflect.DeepEqual(got, tt.wantCoordinates) {
+				t.Errorf("coordinates = %q, want %q", got, tt.wantCoordinates)
+			}
+		})
+	}
+}
+
+func TestFullPrecision(t *testing.T) {
+	locations := []*models.VehicleLocation{
+		{VehicleID: "B1", Latitude: -6.123456789, Longitude: 106.987654321, Timestamp: 1700000000},
+		{VehicleID: "B1", Latitude: -6.12, Longitude: 106.98, Timestamp: 1700000010},
+	}
+
+	for _, format := range []string{FormatGeoJSON, FormatGPX, FormatCSV, FormatKML} {
+		out := string(write(t, format, "B1", locations))
+		for _, coordinate := range []string{"-6.123456789", "106.987654321"} {
+			if !strings.Contains(out, coordinate) {
+				t.Errorf("%s export lost precision, want %s in %s", format, coordinate, out)
+			}
+		}
+	}
+}
+
+func TestImplausibleLeftOut(t *testing.T) {
+	flagged := track(3)
+	flagged[1].Implausible = "impossible_jump"
+	plausible := []*models.VehicleLocation{flagged[0], flagged[2]}
+
+	for _, format := range []string{FormatGeoJSON, FormatGPX, FormatCSV, FormatKML} {
+		got, want := write(t, format, "B1", flagged), write(t, format, "B1", plausible)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s export with a flagged location =\n%s\nwant\n%s", format, got, want)
+		}
+	}
+}
+
+func TestNewWriterUnsupportedFormat(t *testing.T) {
+	if _, err := NewWriter("shp", &bytes.Buffer{}, "B1"); err == nil {
+		t.Error("NewWriter(shp) succeeded, want an error")
+	}
+}
+
+func TestFormatForContentType(t *testing.T) {
+	for _, format := range []string{FormatGeoJSON, FormatGPX, FormatCSV, FormatKML} {
+		if got := FormatForContentType(ContentType(format)); got != format {
+			t.Errorf("FormatForContentType(ContentType(%q)) = %q", format, got)
+		}
+	}
+	if got := FormatForContentType("application/json"); got != "" {
+		t.Errorf("FormatForContentType(application/json) = %q, want none", got)
+	}
+}
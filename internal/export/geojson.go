@@ -0,0 +1,74 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// geoJSONWriter writes a FeatureCollection holding the track as a single LineString feature.
+// Coordinates are streamed, and the properties are written last once the time bounds are known.
+// A LineString needs two positions (RFC 7946 §3.1.4), so a single location is written as a
+// Point and an empty track as an empty FeatureCollection.
+type geoJSONWriter struct {
+	w         io.Writer
+	vehicleID string
+	points    int
+	first     string // position of the first location, held until the geometry type is known
+	startTime int64
+	endTime   int64
+}
+
+func newGeoJSONWriter(w io.Writer, vehicleID string) *geoJSONWriter {
+	return &geoJSONWriter{w: w, vehicleID: vehicleID}
+}
+
+func (g *geoJSONWriter) Begin() error {
+	_, err := io.WriteString(g.w, `{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (g *geoJSONWriter) Write(location *models.VehicleLocation) error {
+	position := "[" + formatCoordinate(location.Longitude) + "," + formatCoordinate(location.Latitude) + "]"
+
+	g.points++
+	g.endTime = location.Timestamp
+
+	var err error
+	switch g.points {
+	case 1:
+		g.startTime = location.Timestamp
+		g.first = position
+	case 2:
+		_, err = fmt.Fprintf(g.w, `{"type":"Feature","geometry":{"type":"LineString","coordinates":[%s,%s`, g.first, position)
+	default:
+		_, err = io.WriteString(g.w, ","+position)
+	}
+	return err
+}
+
+func (g *geoJSONWriter) End() error {
+	if g.points == 0 {
+		_, err := io.WriteString(g.w, `]}`)
+		return err
+	}
+
+	properties, err := json.Marshal(map[string]interface{}{
+		"vehicle_id": g.vehicleID,
+		"points":     g.points,
+		"start_time": g.startTime,
+		"end_time":   g.endTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	if g.points == 1 {
+		_, err = fmt.Fprintf(g.w, `{"type":"Feature","geometry":{"type":"Point","coordinates":%s},"properties":%s}]}`, g.first, properties)
+		return err
+	}
+	_, err = fmt.Fprintf(g.w, `]},"properties":%s}]}`, properties)
+	return err
+}
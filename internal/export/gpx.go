@@ -0,0 +1,48 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// gpxWriter writes a GPX 1.1 document with a single track segment
+type gpxWriter struct {
+	w         io.Writer
+	vehicleID string
+}
+
+func newGPXWriter(w io.Writer, vehicleID string) *gpxWriter {
+	return &gpxWriter{w: w, vehicleID: vehicleID}
+}
+
+func (g *gpxWriter) Begin() error {
+	if _, err := io.WriteString(g.w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(g.w, `<gpx version="1.1" creator="transjakarta-fleet" xmlns="http://www.topografix.com/GPX/1/1">`+"\n<trk><name>"); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(g.w, []byte(g.vehicleID)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(g.w, "</name><trkseg>\n")
+	return err
+}
+
+func (g *gpxWriter) Write(location *models.VehicleLocation) error {
+	_, err := fmt.Fprintf(g.w, "<trkpt lat=\"%s\" lon=\"%s\"><time>%s</time></trkpt>\n",
+		formatCoordinate(location.Latitude),
+		formatCoordinate(location.Longitude),
+		time.Unix(location.Timestamp, 0).UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (g *gpxWriter) End() error {
+	_, err := io.WriteString(g.w, "</trkseg></trk>\n</gpx>\n")
+	return err
+}
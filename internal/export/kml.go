@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// kmlWriter writes a KML document with the track as a LineString placemark.
+// A LineString needs two coordinates, so a single location is written as a
+// Point placemark and an empty track as an empty Document.
+type kmlWriter struct {
+	w         io.Writer
+	vehicleID string
+	points    int
+	first     string // coordinate of the first location, held until the geometry type is known
+}
+
+func newKMLWriter(w io.Writer, vehicleID string) *kmlWriter {
+	return &kmlWriter{w: w, vehicleID: vehicleID}
+}
+
+func (k *kmlWriter) Begin() error {
+	if _, err := io.WriteString(k.w, xml.Header); err != nil {
+		return err
+	}
+	_, err := io.WriteString(k.w, `<kml xmlns="http://www.opengis.net/kml/2.2">`+"\n<Document>")
+	return err
+}
+
+func (k *kmlWriter) Write(location *models.VehicleLocation) error {
+	coordinate := formatCoordinate(location.Longitude) + "," + formatCoordinate(location.Latitude) + ",0\n"
+
+	k.points++
+	switch k.points {
+	case 1:
+		k.first = coordinate
+		return nil
+	case 2:
+		if err := k.placemark("<LineString><tessellate>1</tessellate>"); err != nil {
+			return err
+		}
+		coordinate = k.first + coordinate
+	}
+	_, err := io.WriteString(k.w, coordinate)
+	return err
+}
+
+func (k *kmlWriter) End() error {
+	var err error
+	switch k.points {
+	case 0:
+		_, err = io.WriteString(k.w, "</Document>\n</kml>\n")
+	case 1:
+		if err = k.placemark("<Point>"); err == nil {
+			_, err = io.WriteString(k.w, k.first+"</coordinates></Point></Placemark></Document>\n</kml>\n")
+		}
+	default:
+		_, err = io.WriteString(k.w, "</coordinates></LineString></Placemark></Document>\n</kml>\n")
+	}
+	return err
+}
+
+// placemark opens a placemark and its geometry up to the coordinates
+func (k *kmlWriter) placemark(geometry string) error {
+	if _, err := io.WriteString(k.w, "<Placemark><name>"); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(k.w, []byte(k.vehicleID)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(k.w, "</name>"+geometry+"<coordinates>\n")
+	return err
+}
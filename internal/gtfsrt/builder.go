@@ -0,0 +1,58 @@
+package gtfsrt
+
+import (
+	"time"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// NewVehiclePositionsFeed builds a full-dataset VehiclePositions feed from the latest vehicle locations.
+// GTFS-Realtime cannot mark a position as stale, so stale ones are left out.
+func NewVehiclePositionsFeed(locations []*models.LocationStatus, now time.Time) *FeedMessage {
+	feed := newFeed(now)
+	for _, location := range locations {
+		if location.Stale {
+			continue
+		}
+
+		position := &Position{
+			Latitude:  float32(location.Latitude),
+			Longitude: float32(location.Longitude),
+		}
+		if location.Speed != nil {
+			// GTFS-Realtime speed is in meters per second
+			speed := float32(*location.Speed / 3.6)
+			position.Speed = &speed
+		}
+
+		feed.Entity = append(feed.Entity, &FeedEntity{
+			ID: location.VehicleID,
+			Vehicle: &VehiclePosition{
+				Vehicle: &VehicleDescriptor{
+					ID:    location.VehicleID,
+					Label: location.VehicleID,
+				},
+				Position:  position,
+				Timestamp: uint64(location.Timestamp),
+			},
+		})
+	}
+	return feed
+}
+
+// NewTripUpdatesFeed builds a full-dataset TripUpdates feed.
+// Trip assignments are not tracked yet, so the feed carries only a header.
+func NewTripUpdatesFeed(now time.Time) *FeedMessage {
+	return newFeed(now)
+}
+
+func newFeed(now time.Time) *FeedMessage {
+	return &FeedMessage{
+		Header: FeedHeader{
+			GtfsRealtimeVersion: Version,
+			Incrementality:      FullDataset,
+			Timestamp:           uint64(now.Unix()),
+		},
+		Entity: []*FeedEntity{},
+	}
+}
@@ -0,0 +1,186 @@
+package gtfsrt
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Version is the GTFS-Realtime specification version advertised in feed headers
+const Version = "2.0"
+
+// Incrementality values defined by the GTFS-Realtime specification
+const (
+	FullDataset  = 0
+	Differential = 1
+)
+
+// FeedMessage is the top-level GTFS-Realtime message
+type FeedMessage struct {
+	Header FeedHeader    `json:"header"`
+	Entity []*FeedEntity `json:"entity"`
+}
+
+type FeedHeader struct {
+	GtfsRealtimeVersion string `json:"gtfs_realtime_version"`
+	Incrementality      int    `json:"incrementality"`
+	Timestamp           uint64 `json:"timestamp"`
+}
+
+type FeedEntity struct {
+	ID         string           `json:"id"`
+	IsDeleted  bool             `json:"is_deleted,omitempty"`
+	TripUpdate *TripUpdate      `json:"trip_update,omitempty"`
+	Vehicle    *VehiclePosition `json:"vehicle,omitempty"`
+}
+
+type VehiclePosition struct {
+	Trip      *TripDescriptor    `json:"trip,omitempty"`
+	Vehicle   *VehicleDescriptor `json:"vehicle,omitempty"`
+	Position  *Position          `json:"position,omitempty"`
+	Timestamp uint64             `json:"timestamp,omitempty"`
+}
+
+type TripUpdate struct {
+	Trip      TripDescriptor     `json:"trip"`
+	Vehicle   *VehicleDescriptor `json:"vehicle,omitempty"`
+	Timestamp uint64             `json:"timestamp,omitempty"`
+	Delay     int32              `json:"delay,omitempty"`
+}
+
+type TripDescriptor struct {
+	TripID  string `json:"trip_id,omitempty"`
+	RouteID string `json:"route_id,omitempty"`
+}
+
+type VehicleDescriptor struct {
+	ID    string `json:"id,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+type Position struct {
+	Latitude  float32  `json:"latitude"`
+	Longitude float32  `json:"longitude"`
+	Bearing   *float32 `json:"bearing,omitempty"`
+	Speed     *float32 `json:"speed,omitempty"`
+}
+
+// MarshalProto encodes the feed using the GTFS-Realtime protobuf wire format
+func (f *FeedMessage) MarshalProto() []byte {
+	var b []byte
+	b = appendMessage(b, 1, f.Header.marshal())
+	for _, entity := range f.Entity {
+		b = appendMessage(b, 2, entity.marshal())
+	}
+	return b
+}
+
+func (h *FeedHeader) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, h.GtfsRealtimeVersion)
+	b = appendVarint(b, 2, uint64(h.Incrementality))
+	b = appendVarint(b, 3, h.Timestamp)
+	return b
+}
+
+func (e *FeedEntity) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, e.ID)
+	if e.IsDeleted {
+		b = appendVarint(b, 2, 1)
+	}
+	if e.TripUpdate != nil {
+		b = appendMessage(b, 3, e.TripUpdate.marshal())
+	}
+	if e.Vehicle != nil {
+		b = appendMessage(b, 4, e.Vehicle.marshal())
+	}
+	return b
+}
+
+func (v *VehiclePosition) marshal() []byte {
+	var b []byte
+	if v.Trip != nil {
+		b = appendMessage(b, 1, v.Trip.marshal())
+	}
+	if v.Position != nil {
+		b = appendMessage(b, 2, v.Position.marshal())
+	}
+	if v.Timestamp != 0 {
+		b = appendVarint(b, 5, v.Timestamp)
+	}
+	if v.Vehicle != nil {
+		b = appendMessage(b, 8, v.Vehicle.marshal())
+	}
+	return b
+}
+
+func (t *TripUpdate) marshal() []byte {
+	var b []byte
+	b = appendMessage(b, 1, t.Trip.marshal())
+	if t.Vehicle != nil {
+		b = appendMessage(b, 3, t.Vehicle.marshal())
+	}
+	if t.Timestamp != 0 {
+		b = appendVarint(b, 4, t.Timestamp)
+	}
+	if t.Delay != 0 {
+		b = appendVarint(b, 5, uint64(int64(t.Delay)))
+	}
+	return b
+}
+
+func (t *TripDescriptor) marshal() []byte {
+	var b []byte
+	if t.TripID != "" {
+		b = appendString(b, 1, t.TripID)
+	}
+	if t.RouteID != "" {
+		b = appendString(b, 5, t.RouteID)
+	}
+	return b
+}
+
+func (v *VehicleDescriptor) marshal() []byte {
+	var b []byte
+	if v.ID != "" {
+		b = appendString(b, 1, v.ID)
+	}
+	if v.Label != "" {
+		b = appendString(b, 2, v.Label)
+	}
+	return b
+}
+
+func (p *Position) marshal() []byte {
+	var b []byte
+	b = appendFloat(b, 1, p.Latitude)
+	b = appendFloat(b, 2, p.Longitude)
+	if p.Bearing != nil {
+		b = appendFloat(b, 3, *p.Bearing)
+	}
+	if p.Speed != nil {
+		b = appendFloat(b, 5, *p.Speed)
+	}
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFloat(b []byte, num protowire.Number, v float32) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+	return protowire.AppendFixed32(b, math.Float32bits(v))
+}
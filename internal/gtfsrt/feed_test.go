@@ -0,0 +1,175 @@
+package gtfsrt
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// field is one decoded protobuf field: a nested message as []field, a string, a varint as uint64 or a float as float32
+type field struct {
+	num   protowire.Number
+	value any
+}
+
+// decode splits a message into its fields, failing t on a wire type GTFS-Realtime does not use
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var value any
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			value = math.Float32frombits(v)
+		default:
+			t.Fatalf("field %d has wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields = append(fields, field{num, value})
+	}
+	return fields
+}
+
+// message is a nested message field, numbered as in gtfs-realtime.proto
+func message(num protowire.Number, fields ...field) field {
+	return field{num, fields}
+}
+
+// decodeTree decodes b and, recursively, the fields that nested says are messages
+func decodeTree(t *testing.T, b []byte, nested map[protowire.Number]map[protowire.Number]bool, path protowire.Number) []field {
+	t.Helper()
+
+	fields := decode(t, b)
+	for i, f := range fields {
+		raw, ok := f.value.([]byte)
+		if !ok {
+			continue
+		}
+		if nested[path][f.num] {
+			fields[i].value = decodeTree(t, raw, nested, path*100+f.num)
+		} else {
+			fields[i].value = string(raw)
+		}
+	}
+	return fields
+}
+
+func TestMarshalProto(t *testing.T) {
+	speed := 36.0
+	now := time.Unix(1700000100, 0)
+
+	// Paths to the message-typed fields: FeedMessage is 0, each level down appends its field number
+	nested := map[protowire.Number]map[protowire.Number]bool{
+		0:   {1: true, 2: true},          // FeedMessage.header, entity
+		2:   {3: true, 4: true},          // FeedEntity.trip_update, vehicle
+		204: {1: true, 2: true, 8: true}, // VehiclePosition.trip, position, vehicle
+		203: {1: true, 3: true},          // TripUpdate.trip, vehicle
+	}
+	header := message(1,
+		field{1, Version},             // gtfs_realtime_version
+		field{2, uint64(FullDataset)}, // incrementality
+		field{3, uint64(1700000100)},  // timestamp
+	)
+
+	tests := []struct {
+		name string
+		feed *FeedMessage
+		want []field
+	}{
+		{
+			name: "empty vehicle positions",
+			feed: NewVehiclePositionsFeed(nil, now),
+			want: []field{header},
+		},
+		{
+			name: "vehicle positions without stale ones",
+			feed: NewVehiclePositionsFeed([]*models.LocationStatus{
+				{VehicleLocation: models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: 106.8, Timestamp: 1700000000, Speed: &speed}},
+				{VehicleLocation: models.VehicleLocation{VehicleID: "B2", Latitude: -6.3, Longitude: 106.9, Timestamp: 1700000010}},
+				{VehicleLocation: models.VehicleLocation{VehicleID: "B3", Latitude: -6.4, Longitude: 106.7, Timestamp: 1690000000}, Stale: true},
+			}, now),
+			want: []field{
+				header,
+				message(2,
+					field{1, "B1"}, // id
+					message(4,
+						message(2,
+							field{1, float32(-6.2)},  // latitude
+							field{2, float32(106.8)}, // longitude
+							field{5, float32(10)},    // speed in m/s
+						),
+						field{5, uint64(1700000000)}, // timestamp
+						message(8, field{1, "B1"}, field{2, "B1"}),
+					),
+				),
+				message(2,
+					field{1, "B2"},
+					message(4,
+						message(2, field{1, float32(-6.3)}, field{2, float32(106.9)}),
+						field{5, uint64(1700000010)},
+						message(8, field{1, "B2"}, field{2, "B2"}),
+					),
+				),
+			},
+		},
+		{
+			name: "trip update",
+			feed: &FeedMessage{
+				Header: newFeed(now).Header,
+				Entity: []*FeedEntity{{
+					ID:        "T1",
+					IsDeleted: true,
+					TripUpdate: &TripUpdate{
+						Trip:      TripDescriptor{TripID: "trip-1", RouteID: "1A"},
+						Vehicle:   &VehicleDescriptor{ID: "B1"},
+						Timestamp: 1700000000,
+						Delay:     -90,
+					},
+				}},
+			},
+			want: []field{
+				header,
+				message(2,
+					field{1, "T1"},
+					field{2, uint64(1)}, // is_deleted
+					message(3,
+						message(1, field{1, "trip-1"}, field{5, "1A"}), // trip_id, route_id
+						message(3, field{1, "B1"}),
+						field{4, uint64(1700000000)},
+						field{5, uint64(math.MaxUint64 - 89)}, // int32 -90 sign-extended
+					),
+				),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeTree(t, tt.feed.MarshalProto(), nested, 0)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MarshalProto() decodes to\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports whether a dependency is usable; it should honour ctx cancellation
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of running every registered check
+type Report struct {
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs the registered dependency checks for the readiness endpoint
+type Checker struct {
+	service string
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+}
+
+func NewChecker(service string, timeout time.Duration) *Checker {
+	return &Checker{
+		service: service,
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+// Register adds or replaces the check for a dependency
+func (h *Checker) Register(name string, check CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Check runs all checks concurrently, each bounded by the checker timeout.
+// The report is ready only if every check passed.
+func (h *Checker) Check(ctx context.Context) *Report {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]CheckFunc, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.run(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:  "ready",
+		Service: h.service,
+		Checks:  make(map[string]CheckResult, len(names)),
+	}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = "not_ready"
+		}
+	}
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler reports that the process is running and able to serve requests.
+// It does not check dependencies, so an outage does not get the container restarted.
+func (h *Checker) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "alive",
+			"service": h.service,
+		})
+	}
+}
+
+// ReadinessHandler reports per-dependency status and latency,
+// responding 503 when any dependency is down.
+func (h *Checker) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Check(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Payload encodings
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+	EncodingCBOR     = "cbor"
+)
+
+// Rejection reasons of Decode, in addition to ReasonInvalidJSON
+const (
+	ReasonInvalidProtobuf     = "invalid_protobuf"
+	ReasonInvalidCBOR         = "invalid_cbor"
+	ReasonUnsupportedEncoding = "unsupported_encoding"
+	ReasonEmptyBatch          = "empty_batch"
+)
+
+var contentTypeEncodings = map[string]string{
+	"application/json":                EncodingJSON,
+	"application/x-protobuf":          EncodingProtobuf,
+	"application/protobuf":            EncodingProtobuf,
+	"application/vnd.google.protobuf": EncodingProtobuf,
+	"application/cbor":                EncodingCBOR,
+}
+
+// EncodingForContentType returns the encoding of a MIME type, ignoring its
+// parameters, and false if it is not a supported one
+func EncodingForContentType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	encoding, ok := contentTypeEncodings[mediaType]
+	return encoding, ok
+}
+
+// EncodingForTopic returns the encoding selected by the last level of a location
+// topic: /fleet/vehicle/{id}/location is JSON, while .../location/json,
+// .../location/protobuf and .../location/cbor name their encoding. It returns
+// false for any other suffix.
+func EncodingForTopic(topic string) (string, bool) {
+	switch suffix := path.Base(topic); suffix {
+	case "location":
+		return EncodingJSON, true
+	case EncodingJSON, EncodingProtobuf, EncodingCBOR:
+		return suffix, true
+	default:
+		return "", false
+	}
+}
+
+// DecodeBatch decodes a payload holding one location or a batch of them: a
+// JSON array, a CBOR array or a VehicleLocationBatch Protobuf message. The
+// locations are returned in payload order. It returns a *Rejection.
+func DecodeBatch(encoding string, payload []byte) ([]models.VehicleLocation, error) {
+	var locations []models.VehicleLocation
+
+	switch {
+	case encoding == EncodingJSON && firstNonSpace(payload) == '[':
+		if err := json.Unmarshal(payload, &locations); err != nil {
+			return nil, reject(ReasonInvalidJSON, "%v", err)
+		}
+	case encoding == EncodingCBOR && len(payload) > 0 && payload[0]>>5 == cborArray:
+		if err := cbor.Unmarshal(payload, &locations); err != nil {
+			return nil, reject(ReasonInvalidCBOR, "%v", err)
+		}
+	case encoding == EncodingProtobuf && isProtoBatch(payload):
+		var err error
+		if locations, err = unmarshalProtoBatch(payload); err != nil {
+			return nil, reject(ReasonInvalidProtobuf, "%v", err)
+		}
+	default:
+		var location models.VehicleLocation
+		if err := Decode(encoding, payload, &location); err != nil {
+			return nil, err
+		}
+		return []models.VehicleLocation{location}, nil
+	}
+
+	if len(locations) == 0 {
+		return nil, reject(ReasonEmptyBatch, "batch contains no locations")
+	}
+	return locations, nil
+}
+
+// cborArray is the CBOR major type of arrays, in the top three bits of the first byte
+const cborArray = 4
+
+func firstNonSpace(b []byte) byte {
+	for _, c := range b {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c
+		}
+	}
+	return 0
+}
+
+// Decode decodes a location payload. Protobuf payloads are a VehicleLocation
+// message of proto/fleet/v1/location.proto; JSON and CBOR payloads are maps
+// with the same field names. It returns a *Rejection.
+func Decode(encoding string, payload []byte, location *models.VehicleLocation) error {
+	switch encoding {
+	case EncodingJSON:
+		if err := json.Unmarshal(payload, location); err != nil {
+			return reject(ReasonInvalidJSON, "%v", err)
+		}
+	case EncodingProtobuf:
+		if err := unmarshalProto(payload, location); err != nil {
+			return reject(ReasonInvalidProtobuf, "%v", err)
+		}
+	case EncodingCBOR:
+		// CBOR maps use the json field names
+		if err := cbor.Unmarshal(payload, location); err != nil {
+			return reject(ReasonInvalidCBOR, "%v", err)
+		}
+	default:
+		return reject(ReasonUnsupportedEncoding, "unsupported encoding %q", encoding)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package ingest
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// protoLocation encodes a VehicleLocation message the way a device would
+func protoLocation(location models.VehicleLocation) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, location.VehicleID)
+	b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(location.Latitude))
+	b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(location.Longitude))
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(location.Timestamp))
+	if location.Speed != nil {
+		b = protowire.AppendTag(b, 5, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*location.Speed))
+	}
+	return b
+}
+
+// protoBatch encodes a VehicleLocationBatch message
+func protoBatch(locations ...models.VehicleLocation) []byte {
+	var b []byte
+	for _, location := range locations {
+		b = protowire.AppendTag(b, batchLocationsField, protowire.BytesType)
+		b = protowire.AppendBytes(b, protoLocation(location))
+	}
+	return b
+}
+
+func mustCBOR(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func speed(kmh float64) *float64 {
+	return &kmh
+}
+
+var (
+	b1 = models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: 106.8, Timestamp: 1700000000, Speed: speed(42.5)}
+	b2 = models.VehicleLocation{VehicleID: "B2", Latitude: -6.3, Longitude: 106.9, Timestamp: 1700000010}
+)
+
+func TestDecode(t *testing.T) {
+	// A field a newer schema might add
+	withUnknownField := protowire.AppendTag(protoLocation(b2), 9, protowire.BytesType)
+	withUnknownField = protowire.AppendString(withUnknownField, "route 1")
+
+	tests := []struct {
+		name       string
+		encoding   string
+		payload    []byte
+		want       models.VehicleLocation
+		wantReason string
+	}{
+		{
+			name:     "JSON",
+			encoding: EncodingJSON,
+			payload:  []byte(`{"vehicle_id":"B1","latitude":-6.2,"longitude":106.8,"timestamp":1700000000,"speed":42.5}`),
+			want:     b1,
+		},
+		{
+			name:       "malformed JSON",
+			encoding:   EncodingJSON,
+			payload:    []byte(`{"vehicle_id":`),
+			wantReason: ReasonInvalidJSON,
+		},
+		{
+			name:     "Protobuf",
+			encoding: EncodingProtobuf,
+			payload:  protoLocation(b1),
+			want:     b1,
+		},
+		{
+			name:     "Protobuf with an unknown field",
+			encoding: EncodingProtobuf,
+			payload:  withUnknownField,
+			want:     b2,
+		},
+		{
+			name:       "Protobuf with a wrong wire type",
+			encoding:   EncodingProtobuf,
+			payload:    protowire.AppendVarint(protowire.AppendTag(nil, 2, protowire.VarintType), 1),
+			wantReason: ReasonInvalidProtobuf,
+		},
+		{
+			name:       "truncated Protobuf",
+			encoding:   EncodingProtobuf,
+			payload:    protoLocation(b1)[:5],
+			wantReason: ReasonInvalidProtobuf,
+		},
+		{
+			name:     "CBOR",
+			encoding: EncodingCBOR,
+			payload: mustCBOR(t, map[string]any{
+				"vehicle_id": "B1", "latitude": -6.2, "longitude": 106.8, "timestamp": 1700000000, "speed": 42.5,
+			}),
+			want: b1,
+		},
+		{
+			name:       "malformed CBOR",
+			encoding:   EncodingCBOR,
+			payload:    []byte{0xa1},
+			wantReason: ReasonInvalidCBOR,
+		},
+		{
+			name:       "unsupported encoding",
+			encoding:   "xml",
+			payload:    []byte(`<location/>`),
+			wantReason: ReasonUnsupportedEncoding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.VehicleLocation
+			err := Decode(tt.encoding, tt.payload, &got)
+			checkRejection(t, err, tt.wantReason)
+			if tt.wantReason == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		encoding   string
+		payload    []byte
+		want       []models.VehicleLocation
+		wantReason string
+	}{
+		{
+			name:     "JSON object",
+			encoding: EncodingJSON,
+			payload:  []byte(`{"vehicle_id":"B2","latitude":-6.3,"longitude":106.9,"timestamp":1700000010}`),
+			want:     []models.VehicleLocation{b2},
+		},
+		{
+			name:     "JSON array",
+			encoding: EncodingJSON,
+			payload: []byte(` [{"vehicle_id":"B1","latitude":-6.2,"longitude":106.8,"timestamp":1700000000,"speed":42.5},
+				{"vehicle_id":"B2","latitude":-6.3,"longitude":106.9,"timestamp":1700000010}]`),
+			want: []models.VehicleLocation{b1, b2},
+		},
+		{
+			name:       "empty JSON array",
+			encoding:   EncodingJSON,
+			payload:    []byte(`[]`),
+			wantReason: ReasonEmptyBatch,
+		},
+		{
+			name:     "Protobuf location",
+			encoding: EncodingProtobuf,
+			payload:  protoLocation(b1),
+			want:     []models.VehicleLocation{b1},
+		},
+		{
+			name:     "Protobuf batch",
+			encoding: EncodingProtobuf,
+			payload:  protoBatch(b1, b2),
+			want:     []models.VehicleLocation{b1, b2},
+		},
+		{
+			name:       "Protobuf batch with a malformed location",
+			encoding:   EncodingProtobuf,
+			payload:    protowire.AppendBytes(protowire.AppendTag(protoBatch(b1), batchLocationsField, protowire.BytesType), []byte{0x0a, 0x05}),
+			wantReason: ReasonInvalidProtobuf,
+		},
+		{
+			name:     "CBOR map",
+			encoding: EncodingCBOR,
+			payload:  mustCBOR(t, map[string]any{"vehicle_id": "B2", "latitude": -6.3, "longitude": 106.9, "timestamp": 1700000010}),
+			want:     []models.VehicleLocation{b2},
+		},
+		{
+			name:     "CBOR array",
+			encoding: EncodingCBOR,
+			payload: mustCBOR(t, []map[string]any{
+				{"vehicle_id": "B1", "latitude": -6.2, "longitude": 106.8, "timestamp": 1700000000, "speed": 42.5},
+				{"vehicle_id": "B2", "latitude": -6.3, "longitude": 106.9, "timestamp": 1700000010},
+			}),
+			want: []models.VehicleLocation{b1, b2},
+		},
+		{
+			name:       "empty CBOR array",
+			encoding:   EncodingCBOR,
+			payload:    mustCBOR(t, []any{}),
+			wantReason: ReasonEmptyBatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeBatch(tt.encoding, tt.payload)
+			checkRejection(t, err, tt.wantReason)
+			if tt.wantReason == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeBatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodingForContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+		wantOK      bool
+	}{
+		{"application/json", EncodingJSON, true},
+		{"application/x-protobuf", EncodingProtobuf, true},
+		{"application/cbor; charset=binary", EncodingCBOR, true},
+		{"text/plain", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := EncodingForContentType(tt.contentType)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("EncodingForContentType(%q) = %q, %v, want %q, %v", tt.contentType, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestEncodingForTopic(t *testing.T) {
+	tests := []struct {
+		topic  string
+		want   string
+		wantOK bool
+	}{
+		{"/fleet/vehicle/B1/location", EncodingJSON, true},
+		{"/fleet/vehicle/B1/location/json", EncodingJSON, true},
+		{"/fleet/vehicle/B1/location/protobuf", EncodingProtobuf, true},
+		{"/fleet/vehicle/B1/location/cbor", EncodingCBOR, true},
+		{"/fleet/vehicle/B1/location/xml", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := EncodingForTopic(tt.topic)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("EncodingForTopic(%q) = %q, %v, want %q, %v", tt.topic, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// unmarshalProto decodes a VehicleLocation message of proto/fleet/v1/location.proto.
+// Unknown fields are skipped so devices can run a newer schema.
+func unmarshalProto(b []byte, location *models.VehicleLocation) error {
+	*location = models.VehicleLocation{}
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var err error
+		switch num {
+		case 1:
+			location.VehicleID, n, err = consumeString(b, typ)
+		case 2:
+			location.Latitude, n, err = consumeDouble(b, typ)
+		case 3:
+			location.Longitude, n, err = consumeDouble(b, typ)
+		case 4:
+			var v uint64
+			v, n, err = consumeVarint(b, typ)
+			location.Timestamp = int64(v)
+		case 5:
+			var speed float64
+			speed, n, err = consumeDouble(b, typ)
+			location.Speed = &speed
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+
+	return nil
+}
+
+// batchLocationsField is the field of VehicleLocationBatch holding its locations.
+// No VehicleLocation field has this number, so a message that has it is a batch.
+const batchLocationsField = 16
+
+// isProtoBatch reports whether b is a VehicleLocationBatch message
+func isProtoBatch(b []byte) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		if num == batchLocationsField {
+			return true
+		}
+		b = b[n:]
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return false
+}
+
+// unmarshalProtoBatch decodes a VehicleLocationBatch message
+func unmarshalProtoBatch(b []byte) ([]models.VehicleLocation, error) {
+	var locations []models.VehicleLocation
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if num != batchLocationsField {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+			continue
+		}
+
+		if typ != protowire.BytesType {
+			return nil, fmt.Errorf("field %d: %w", num, errWireType)
+		}
+		msg, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var location models.VehicleLocation
+		if err := unmarshalProto(msg, &location); err != nil {
+			return nil, fmt.Errorf("location %d: %w", len(locations), err)
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, nil
+}
+
+var errWireType = errors.New("unexpected wire type")
+
+func consumeString(b []byte, typ protowire.Type) (string, int, error) {
+	if typ != protowire.BytesType {
+		return "", 0, errWireType
+	}
+	v, n := protowire.ConsumeString(b)
+	return v, n, nil
+}
+
+func consumeDouble(b []byte, typ protowire.Type) (float64, int, error) {
+	if typ != protowire.Fixed64Type {
+		return 0, 0, errWireType
+	}
+	v, n := protowire.ConsumeFixed64(b)
+	return math.Float64frombits(v), n, nil
+}
+
+func consumeVarint(b []byte, typ protowire.Type) (uint64, int, error) {
+	if typ != protowire.VarintType {
+		return 0, 0, errWireType
+	}
+	v, n := protowire.ConsumeVarint(b)
+	return v, n, nil
+}
@@ -0,0 +1,94 @@
+// Package ingest holds the checks shared by every path that accepts vehicle
+// locations, so MQTT messages and HTTP uploads are judged the same way.
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// Rejection reasons, used as log fields and metric labels
+const (
+	ReasonInvalidJSON      = "invalid_json"
+	ReasonMissingVehicleID = "missing_vehicle_id"
+	ReasonInvalidVehicleID = "invalid_vehicle_id"
+	ReasonInvalidLatitude  = "invalid_latitude"
+	ReasonInvalidLongitude = "invalid_longitude"
+	ReasonFutureTimestamp  = "future_timestamp"
+)
+
+// Reasons of the plausibility filter, which rejects or flags a location
+const (
+	ReasonNullIsland         = "null_island"
+	ReasonOutsideServiceArea = "outside_service_area"
+	ReasonImpossibleJump     = "impossible_jump"
+)
+
+// Rejection is a location that failed validation
+type Rejection struct {
+	Reason  string
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+func reject(reason, format string, args ...any) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// maxVehicleIDLength matches the vehicle_id columns
+const maxVehicleIDLength = 50
+
+// Validate checks that a location names its vehicle and has coordinates in range.
+// It returns a *Rejection.
+func Validate(location *models.VehicleLocation) error {
+	if err := ValidateVehicleID(location.VehicleID); err != nil {
+		return err
+	}
+
+	if location.Latitude < -90 || location.Latitude > 90 {
+		return reject(ReasonInvalidLatitude, "latitude %v is out of range", location.Latitude)
+	}
+
+	if location.Longitude < -180 || location.Longitude > 180 {
+		return reject(ReasonInvalidLongitude, "longitude %v is out of range", location.Longitude)
+	}
+
+	return nil
+}
+
+// ValidateVehicleID checks that a vehicle ID is present, fits the database and
+// can stand as a single MQTT topic level: no /, + or # and no control characters.
+// It returns a *Rejection.
+func ValidateVehicleID(vehicleID string) error {
+	if vehicleID == "" {
+		return reject(ReasonMissingVehicleID, "vehicle_id is required")
+	}
+
+	if len(vehicleID) > maxVehicleIDLength {
+		return reject(ReasonInvalidVehicleID, "vehicle_id is longer than %d bytes", maxVehicleIDLength)
+	}
+
+	if strings.ContainsAny(vehicleID, "/+#") || strings.ContainsFunc(vehicleID, unicode.IsControl) {
+		return reject(ReasonInvalidVehicleID, "vehicle_id %q contains /, +, # or a control character", vehicleID)
+	}
+
+	return nil
+}
+
+// CheckTimestamp rejects a location dated more than maxSkew after now, which
+// would otherwise pass for the vehicle's newest position until the clock caught up.
+// It returns a *Rejection.
+func CheckTimestamp(location *models.VehicleLocation, now time.Time, maxSkew time.Duration) error {
+	if limit := now.Add(maxSkew).Unix(); location.Timestamp > limit {
+		return reject(ReasonFutureTimestamp, "timestamp %d is %ds in the future, more than the allowed %s",
+			location.Timestamp, location.Timestamp-now.Unix(), maxSkew)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"transjakarta-fleet/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   models.VehicleLocation
+		wantReason string
+	}{
+		{
+			name:     "valid",
+			location: models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: 106.8},
+		},
+		{
+			name:     "coordinates on the bounds",
+			location: models.VehicleLocation{VehicleID: "B1", Latitude: -90, Longitude: 180},
+		},
+		{
+			name:       "missing vehicle ID",
+			location:   models.VehicleLocation{Latitude: -6.2, Longitude: 106.8},
+			wantReason: ReasonMissingVehicleID,
+		},
+		{
+			name:       "vehicle ID with a topic separator",
+			location:   models.VehicleLocation{VehicleID: "B1/location", Latitude: -6.2, Longitude: 106.8},
+			wantReason: ReasonInvalidVehicleID,
+		},
+		{
+			name:       "latitude out of range",
+			location:   models.VehicleLocation{VehicleID: "B1", Latitude: 90.5, Longitude: 106.8},
+			wantReason: ReasonInvalidLatitude,
+		},
+		{
+			name:       "longitude out of range",
+			location:   models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: -180.5},
+			wantReason: ReasonInvalidLongitude,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkRejection(t, Validate(&tt.location), tt.wantReason)
+		})
+	}
+}
+
+func TestValidateVehicleID(t *testing.T) {
+	tests := []struct {
+		vehicleID  string
+		wantReason string
+	}{
+		{vehicleID: "B1234"},
+		{vehicleID: "TJ-01.A_7"},
+		{vehicleID: strings.Repeat("x", maxVehicleIDLength)},
+		{vehicleID: "", wantReason: ReasonMissingVehicleID},
+		{vehicleID: strings.Repeat("x", maxVehicleIDLength+1), wantReason: ReasonInvalidVehicleID},
+		{vehicleID: "B1/events", wantReason: ReasonInvalidVehicleID},
+		{vehicleID: "+", wantReason: ReasonInvalidVehicleID},
+		{vehicleID: "B#1", wantReason: ReasonInvalidVehicleID},
+		{vehicleID: "B1\n", wantReason: ReasonInvalidVehicleID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.vehicleID, func(t *testing.T) {
+			checkRejection(t, ValidateVehicleID(tt.vehicleID), tt.wantReason)
+		})
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		timestamp  int64
+		wantReason string
+	}{
+		{name: "past", timestamp: now.Unix() - 3600},
+		{name: "now", timestamp: now.Unix()},
+		{name: "within the skew", timestamp: now.Unix() + 60},
+		{name: "beyond the skew", timestamp: now.Unix() + 61, wantReason: ReasonFutureTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := &models.VehicleLocation{VehicleID: "B1", Timestamp: tt.timestamp}
+			checkRejection(t, CheckTimestamp(location, now, time.Minute), tt.wantReason)
+		})
+	}
+}
+
+// checkRejection fails t unless err is a *Rejection with wantReason, or nil if wantReason is empty
+func checkRejection(t *testing.T, err error, wantReason string) {
+	t.Helper()
+
+	if wantReason == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("error = %v, want a rejection with reason %s", err, wantReason)
+	}
+	if rejection.Reason != wantReason {
+		t.Fatalf("reason = %s, want %s", rejection.Reason, wantReason)
+	}
+}
@@ -0,0 +1,150 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errSignal marks a shutdown requested by the operating system rather than a failure
+var errSignal = errors.New("received signal")
+
+// Hook releases a component during shutdown; it should return once ctx is done
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// Manager coordinates background goroutines and ordered shutdown.
+// Shutdown starts on SIGINT/SIGTERM or when a goroutine started with Go fails;
+// stop hooks then run one at a time in the order they were registered.
+type Manager struct {
+	timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	workersCtx    context.Context
+	cancelWorkers context.CancelFunc
+	workers       sync.WaitGroup
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+// New returns a Manager that listens for termination signals.
+// timeout bounds the whole shutdown sequence.
+func New(timeout time.Duration) *Manager {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+
+	m := &Manager{
+		timeout:       timeout,
+		ctx:           ctx,
+		cancel:        cancel,
+		workersCtx:    workersCtx,
+		cancelWorkers: cancelWorkers,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			m.cancel(fmt.Errorf("%w %s", errSignal, sig))
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return m
+}
+
+// Context is cancelled as soon as shutdown starts
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs fn in a goroutine. Its context stays valid until StopWorkers runs,
+// so workers keep going while earlier stop hooks drain traffic into them.
+// If fn returns an error, shutdown is started.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		if err := fn(m.workersCtx); err != nil {
+			slog.Error("Component failed", "component", name, "error", err)
+			m.Stop(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+}
+
+// OnStop registers a hook to run during shutdown, after the hooks registered before it
+func (m *Manager) OnStop(name string, fn Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// StopWorkers is a stop hook that cancels goroutines started with Go and waits for them to return
+func (m *Manager) StopWorkers(ctx context.Context) error {
+	m.cancelWorkers()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop starts shutdown with the given cause
+func (m *Manager) Stop(cause error) {
+	m.cancel(cause)
+}
+
+// Wait blocks until shutdown starts, then runs the stop hooks.
+// It returns the error that caused shutdown, if any, joined with hook failures.
+func (m *Manager) Wait() error {
+	<-m.ctx.Done()
+	cause := context.Cause(m.ctx)
+	slog.Info("Shutting down", "reason", cause.Error(), "timeout", m.timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	if !errors.Is(cause, errSignal) {
+		errs = append(errs, cause)
+	}
+
+	for _, h := range hooks {
+		start := time.Now()
+		if err := h.fn(ctx); err != nil {
+			slog.Error("Shutdown step failed", "component", h.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		slog.Info("Stopped", "component", h.name, "duration_ms", time.Since(start).Milliseconds())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware assigns each request an ID, taken from X-Request-ID when the client
+// sends one, attaches a request-scoped logger to the request context and writes
+// an access log line once the request completes.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = NewID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		lvl := slog.LevelInfo
+		switch {
+		case status >= 500:
+			lvl = slog.LevelError
+		case status >= 400:
+			lvl = slog.LevelWarn
+		}
+
+		logger.Log(c.Request.Context(), lvl, "HTTP request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
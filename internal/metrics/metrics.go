@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "fleet"
+
+var (
+	MQTTMessagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mqtt",
+		Name:      "messages_received_total",
+		Help:      "MQTT location messages received.",
+	})
+	MQTTMessagesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mqtt",
+		Name:      "messages_rejected_total",
+		Help:      "MQTT location messages that could not be decoded, and locations rejected by validation, by reason.",
+	}, []string{"reason"})
+	MQTTMessagesSaved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mqtt",
+		Name:      "messages_saved_total",
+		Help:      "Locations received over MQTT and saved to the database; a batch message counts each location.",
+	})
+	MQTTMessagesFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mqtt",
+		Name:      "messages_failed_total",
+		Help:      "Locations received over MQTT that passed validation but could not be saved.",
+	})
+
+	HTTPLocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "locations_total",
+		Help:      "Locations uploaded over HTTP, by outcome: saved or the reason the record was rejected.",
+	}, []string{"outcome"})
+
+	LateLocations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "late_locations_total",
+		Help:      "Locations saved as late because the vehicle had already reported a newer one.",
+	})
+
+	ImplausibleLocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "implausible_locations_total",
+		Help:      "Locations caught by the plausibility filter, by reason and whether they were rejected or flagged.",
+	}, []string{"reason", "mode"})
+
+	GeofenceHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "geofence_hits_total",
+		Help:      "Locations that fell inside the geofence.",
+	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of database-backed service methods.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "Database-backed service method calls that returned an error.",
+	}, []string{"method"})
+
+	RabbitMQPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "published_total",
+		Help:      "Events published to RabbitMQ, by routing key and outcome.",
+	}, []string{"routing_key", "outcome"})
+	RabbitMQConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "consumed_total",
+		Help:      "Events consumed from RabbitMQ, by queue and outcome.",
+	}, []string{"queue", "outcome"})
+
+	EventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "published_total",
+		Help:      "Events published to NATS, Kafka or MQTT, by bus, routing key and outcome.",
+	}, []string{"bus", "routing_key", "outcome"})
+
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// ObserveQuery records the duration and outcome of a database-backed service method.
+// It is meant to be deferred with a pointer to the method's named error result:
+//
+//	defer metrics.ObserveQuery("GetLastLocation", time.Now(), &err)
+func ObserveQuery(method string, start time.Time, err *error) {
+	DBQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		DBQueryErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObservePublish records the outcome of publishing an event
+func ObservePublish(routingKey string, err error) {
+	RabbitMQPublished.WithLabelValues(routingKey, outcome(err)).Inc()
+}
+
+// ObserveEventPublish records the outcome of publishing an event to a bus other than RabbitMQ
+func ObserveEventPublish(bus, routingKey string, err error) {
+	EventsPublished.WithLabelValues(bus, routingKey, outcome(err)).Inc()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// HTTPMiddleware records request counts and latency per matched route
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	OperatorID string     `json:"operator_id"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Key is the plaintext key, only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"required"`
+	// OperatorID defaults to the caller's operator; only the super-tenant may set another operator
+	OperatorID string `json:"operator_id"`
+}
@@ -1,11 +1,36 @@
 package models
 
+import "time"
+
 type VehicleLocation struct {
-	ID        int     `json:"id,omitempty"`
-	VehicleID string  `json:"vehicle_id" binding:"required"`
-	Latitude  float64 `json:"latitude" binding:"required"`
-	Longitude float64 `json:"longitude" binding:"required"`
-	Timestamp int64   `json:"timestamp" binding:"required"`
+	ID          int      `json:"id,omitempty"`
+	VehicleID   string   `json:"vehicle_id" binding:"required"`
+	Latitude    float64  `json:"latitude" binding:"required"`
+	Longitude   float64  `json:"longitude" binding:"required"`
+	Timestamp   int64    `json:"timestamp" binding:"required"`
+	Speed       *float64 `json:"speed,omitempty"` // km/h, as reported by the device
+	OperatorID  string   `json:"operator_id,omitempty"`
+	Late        bool     `json:"late,omitempty"`        // older than a location the vehicle already reported
+	Implausible string   `json:"implausible,omitempty"` // why the plausibility filter flagged it
+}
+
+// Vehicle is the registration of a vehicle to the operator that runs it
+type Vehicle struct {
+	VehicleID  string    `json:"vehicle_id"`
+	OperatorID string    `json:"operator_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type RegisterVehicleRequest struct {
+	OperatorID string `json:"operator_id" binding:"required"`
+}
+
+// LocationStatus is a location annotated with how old it is
+type LocationStatus struct {
+	VehicleLocation
+	Stale      bool  `json:"stale"`
+	AgeSeconds int64 `json:"age_seconds"`
 }
 
 type GeofenceEvent struct {
@@ -15,7 +40,74 @@ type GeofenceEvent struct {
 	Timestamp int64    `json:"timestamp"`
 }
 
+type DrivingEvent struct {
+	VehicleID     string   `json:"vehicle_id"`
+	Event         string   `json:"event"`
+	Location      Location `json:"location"`
+	Timestamp     int64    `json:"timestamp"`
+	SpeedKmh      float64  `json:"speed_kmh"`
+	SpeedLimitKmh float64  `json:"speed_limit_kmh,omitempty"`
+	Acceleration  float64  `json:"acceleration,omitempty"`
+	Zone          string   `json:"zone,omitempty"`
+}
+
+type PresenceEvent struct {
+	VehicleID string   `json:"vehicle_id"`
+	Event     string   `json:"event"`
+	Location  Location `json:"location"`
+	LastSeen  int64    `json:"last_seen"`
+	Timestamp int64    `json:"timestamp"`
+}
+
 type Location struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 }
+
+type VehicleStats struct {
+	VehicleID     string          `json:"vehicle_id"`
+	StartTime     int64           `json:"start_time"`
+	EndTime       int64           `json:"end_time"`
+	Points        int             `json:"points"`
+	DistanceKm    float64         `json:"distance_km"`
+	MovingSeconds int64           `json:"moving_seconds"`
+	IdleSeconds   int64           `json:"idle_seconds"`
+	MaxSpeedKmh   float64         `json:"max_speed_kmh"`
+	AvgSpeedKmh   float64         `json:"avg_speed_kmh"`
+	Stops         int             `json:"stops"`
+	Daily         []DailyDistance `json:"daily"`
+}
+
+type DailyDistance struct {
+	Date       string  `json:"date"`
+	DistanceKm float64 `json:"distance_km"`
+}
+
+type FleetStats struct {
+	StartTime     int64           `json:"start_time"`
+	EndTime       int64           `json:"end_time"`
+	Vehicles      int             `json:"vehicles"`
+	DistanceKm    float64         `json:"distance_km"`
+	MovingSeconds int64           `json:"moving_seconds"`
+	IdleSeconds   int64           `json:"idle_seconds"`
+	MaxSpeedKmh   float64         `json:"max_speed_kmh"`
+	AvgSpeedKmh   float64         `json:"avg_speed_kmh"`
+	Stops         int             `json:"stops"`
+	PerVehicle    []*VehicleStats `json:"per_vehicle"`
+}
+
+// IngestResult reports the outcome of a location upload
+type IngestResult struct {
+	Accepted int           `json:"accepted"`
+	Late     int           `json:"late"` // accepted records older than the vehicle's newest location
+	Rejected int           `json:"rejected"`
+	Errors   []IngestError `json:"errors"`
+}
+
+// IngestError describes an uploaded record that was not saved
+type IngestError struct {
+	Index     int    `json:"index"` // zero-based position of the record in the upload
+	VehicleID string `json:"vehicle_id,omitempty"`
+	Reason    string `json:"reason"`
+	Error     string `json:"error"`
+}
@@ -1,110 +1,375 @@
 package mqtt
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
 	"transjakarta-fleet/internal/models"
-	"transjakarta-fleet/internal/services"
+	"transjakarta-fleet/internal/tracing"
 )
 
+// locationTopic matches the location topic of every vehicle. Payloads are JSON
+// unless a level below it or the MQTT v5 content type names another encoding.
+const locationTopic = "/fleet/vehicle/+/location"
+
+var locationTopics = []string{locationTopic, locationTopic + "/+"}
+
+// connectTimeout bounds how long Connect waits for the broker
+const connectTimeout = 10 * time.Second
+
+// LocationSaver stores accepted locations in timestamp order and runs the
+// geofence and driving rule checks on them. The result of each location it
+// gets to is passed to done. It returns how many it saved.
+type LocationSaver interface {
+	SaveLocations(ctx context.Context, locations []*models.VehicleLocation, done func(*models.VehicleLocation, error)) (int, error)
+}
+
+// MQTTClient subscribes to vehicle locations over MQTT v5
 type MQTTClient struct {
-	client         mqtt.Client
 	cfg            *config.Config
-	vehicleService *services.VehicleService
+	vehicleService LocationSaver
+
+	mu      sync.Mutex // guards conn and stopped
+	conn    *autopaho.ConnectionManager
+	stopped bool
+
+	connected  atomic.Bool
+	connectErr atomic.Pointer[error] // last failed connection attempt
+	inflight   sync.WaitGroup        // messages being handled, so Stop can wait for their writes
 }
 
-func NewMQTTClient(cfg *config.Config, vehicleService *services.VehicleService) *MQTTClient {
+func NewMQTTClient(cfg *config.Config, vehicleService LocationSaver) *MQTTClient {
 	return &MQTTClient{
 		cfg:            cfg,
 		vehicleService: vehicleService,
 	}
 }
 
+// Connect starts connecting to the broker and waits for the first connection.
+// The connection is kept up in the background from then on, so a failed
+// Connect can simply be called again.
 func (m *MQTTClient) Connect() error {
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(m.cfg.MQTTBroker)
-	opts.SetClientID(m.cfg.MQTTClientID)
-	
-	if m.cfg.MQTTUsername != "" {
-		opts.SetUsername(m.cfg.MQTTUsername)
-		opts.SetPassword(m.cfg.MQTTPassword)
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return errors.New("MQTT client stopped")
 	}
+	if m.conn == nil {
+		conn, err := m.newConnection()
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		m.conn = conn
+	}
+	conn := m.conn
+	m.mu.Unlock()
 
-	opts.SetDefaultPublishHandler(m.messageHandler)
-	opts.SetOnConnectHandler(m.onConnect)
-	opts.SetConnectionLostHandler(m.onConnectionLost)
-	opts.SetAutoReconnect(true)
-	opts.SetKeepAlive(60 * time.Second)
-	opts.SetPingTimeout(10 * time.Second)
-
-	m.client = mqtt.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
 
-	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	if err := conn.AwaitConnection(ctx); err != nil {
+		if last := m.connectErr.Load(); last != nil {
+			err = *last
+		}
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
 	}
 
-	log.Println("Successfully connected to MQTT broker")
+	slog.Info("Connected to MQTT broker", "broker", m.cfg.MQTTBroker)
 	return nil
 }
 
-func (m *MQTTClient) onConnect(client mqtt.Client) {
-	log.Println("MQTT client connected, subscribing to topics...")
-	
-	// Subscribe to all vehicle location topics
-	topic := "/fleet/vehicle/+/location"
-	if token := client.Subscribe(topic, 1, m.messageHandler); token.Wait() && token.Error() != nil {
-		log.Printf("Failed to subscribe to topic %s: %v", topic, token.Error())
+func (m *MQTTClient) newConnection() (*autopaho.ConnectionManager, error) {
+	broker, err := url.Parse(m.cfg.MQTTBroker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{broker},
+		KeepAlive:                     60,
+		CleanStartOnInitialConnection: true,
+		ConnectRetryDelay:             5 * time.Second,
+		ConnectTimeout:                connectTimeout,
+		OnConnectionUp:                m.onConnect,
+		OnConnectError: func(err error) {
+			m.connectErr.Store(&err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:          m.cfg.MQTTClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){m.onPublishReceived},
+			OnClientError:     m.onConnectionLost,
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				m.onConnectionLost(fmt.Errorf("disconnected by broker, reason code %d", d.ReasonCode))
+			},
+		},
+	}
+	if m.cfg.MQTTUsername != "" {
+		cfg.ConnectUsername = m.cfg.MQTTUsername
+		cfg.ConnectPassword = []byte(m.cfg.MQTTPassword)
+	}
+
+	// Disconnect cancels the connection, not this context
+	conn, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MQTT connection: %w", err)
+	}
+	return conn, nil
+}
+
+func (m *MQTTClient) onConnect(conn *autopaho.ConnectionManager, _ *paho.Connack) {
+	m.connected.Store(true)
+	slog.Info("MQTT client connected, subscribing to topics")
+
+	m.mu.Lock()
+	stopped := m.stopped
+	m.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	// The session does not outlive the connection, so subscribe on every reconnect
+	subscribe := &paho.Subscribe{}
+	for _, topic := range locationTopics {
+		subscribe.Subscriptions = append(subscribe.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: 1})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if _, err := conn.Subscribe(ctx, subscribe); err != nil {
+		slog.Error("Failed to subscribe to topics", "topics", locationTopics, "error", err)
 	} else {
-		log.Printf("Successfully subscribed to topic: %s", topic)
+		slog.Info("Subscribed to topics", "topics", locationTopics)
 	}
 }
 
-func (m *MQTTClient) onConnectionLost(client mqtt.Client, err error) {
-	log.Printf("MQTT connection lost: %v", err)
+func (m *MQTTClient) onConnectionLost(err error) {
+	if m.connected.Swap(false) {
+		slog.Warn("MQTT connection lost", "error", err)
+	}
 }
 
-func (m *MQTTClient) messageHandler(client mqtt.Client, msg mqtt.Message) {
-	log.Printf("Received message on topic %s: %s", msg.Topic(), string(msg.Payload()))
+func (m *MQTTClient) onPublishReceived(received paho.PublishReceived) (bool, error) {
+	m.handleMessage(received.Packet)
+	return true, nil
+}
 
-	var location models.VehicleLocation
-	if err := json.Unmarshal(msg.Payload(), &location); err != nil {
-		log.Printf("Failed to unmarshal message: %v", err)
+func (m *MQTTClient) handleMessage(msg *paho.Publish) {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
 		return
 	}
+	m.inflight.Add(1)
+	m.mu.Unlock()
+	defer m.inflight.Done()
 
-	// Validate location data
-	if location.VehicleID == "" {
-		log.Println("Invalid location data: missing vehicle_id")
-		return
+	// Each message starts a trace that follows it through saving and publishing
+	ctx, span := tracing.Start(context.Background(), msg.Topic+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("mqtt"),
+			semconv.MessagingOperationReceive,
+			semconv.MessagingDestinationName(msg.Topic),
+		),
+	)
+	defer span.End()
+
+	// Every log line of this message carries its ID, including those written while saving and publishing
+	messageID := logging.NewID()
+	logger := slog.Default().With("message_id", messageID, "topic", msg.Topic)
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		logger = logger.With("trace_id", traceID)
+	}
+	ctx = logging.WithLogger(ctx, logger)
+	span.SetAttributes(semconv.MessagingMessageID(messageID))
+
+	// A slow database or broker must not hold up the messages queued behind this one
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.MQTTMessageTimeout)
+	defer cancel()
+
+	encoding, err := messageEncoding(msg)
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("Received message", "encoding", encoding, "payload", payloadForLog(encoding, msg.Payload))
+	}
+	metrics.MQTTMessagesReceived.Inc()
+
+	var locations []models.VehicleLocation
+	if err == nil {
+		locations, err = ingest.DecodeBatch(encoding, msg.Payload)
 	}
 
-	if location.Latitude < -90 || location.Latitude > 90 {
-		log.Printf("Invalid latitude: %f", location.Latitude)
+	var rejection *ingest.Rejection
+	if errors.As(err, &rejection) {
+		logger.Warn("Rejected message", "reason", rejection.Reason, "error", rejection)
+		span.SetAttributes(attribute.String("rejected.reason", rejection.Reason))
+		metrics.MQTTMessagesRejected.WithLabelValues(rejection.Reason).Inc()
 		return
 	}
+	if err != nil {
+		logger.Error("Failed to decode message", "error", err)
+		tracing.RecordError(span, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("locations.count", len(locations)))
 
-	if location.Longitude < -180 || location.Longitude > 180 {
-		log.Printf("Invalid longitude: %f", location.Longitude)
+	// Invalid points of a batch are dropped on their own, the rest is still saved
+	accepted := make([]*models.VehicleLocation, 0, len(locations))
+	for i := range locations {
+		location := &locations[i]
+		if errors.As(ingest.Validate(location), &rejection) {
+			logger.Warn("Rejected location", "reason", rejection.Reason, "vehicle_id", location.VehicleID, "index", i, "error", rejection)
+			metrics.MQTTMessagesRejected.WithLabelValues(rejection.Reason).Inc()
+			continue
+		}
+		accepted = append(accepted, location)
+	}
+	// rejection is that of the last location, nil if the batch held none
+	if len(accepted) == 0 {
+		if rejection != nil {
+			span.SetAttributes(attribute.String("rejected.reason", rejection.Reason))
+		}
 		return
 	}
+	span.SetAttributes(attribute.String("vehicle.id", accepted[0].VehicleID))
 
-	// Save location to database
-	if err := m.vehicleService.SaveLocation(&location); err != nil {
-		log.Printf("Failed to save location: %v", err)
+	// Save locations to database
+	unsaved := len(accepted)
+	saved, err := m.vehicleService.SaveLocations(ctx, accepted, func(location *models.VehicleLocation, err error) {
+		var rejection *ingest.Rejection
+		if !errors.As(err, &rejection) {
+			return
+		}
+		logger.Warn("Rejected location", "reason", rejection.Reason, "vehicle_id", location.VehicleID, "timestamp", location.Timestamp, "error", rejection)
+		metrics.MQTTMessagesRejected.WithLabelValues(rejection.Reason).Inc()
+		unsaved--
+	})
+	unsaved -= saved
+	metrics.MQTTMessagesSaved.Add(float64(saved))
+	if err != nil {
+		logger.Error("Failed to save location", "vehicle_id", accepted[0].VehicleID, "saved", saved, "unsaved", unsaved, "error", err)
+		tracing.RecordError(span, err)
+		metrics.MQTTMessagesFailed.Add(float64(unsaved))
 		return
 	}
 
-	log.Printf("Successfully saved location for vehicle %s", location.VehicleID)
+	late := 0
+	for _, location := range accepted {
+		if location.Late {
+			late++
+		}
+	}
+	logger.Debug("Saved locations", "vehicle_id", accepted[0].VehicleID, "count", saved, "late", late)
+}
+
+// messageEncoding picks the payload encoding from the MQTT v5 content type if
+// the publisher set a supported one, otherwise from the topic. It returns a
+// *ingest.Rejection if neither names a supported encoding.
+func messageEncoding(msg *paho.Publish) (string, error) {
+	if msg.Properties != nil && msg.Properties.ContentType != "" {
+		if encoding, ok := ingest.EncodingForContentType(msg.Properties.ContentType); ok {
+			return encoding, nil
+		}
+	}
+	if encoding, ok := ingest.EncodingForTopic(msg.Topic); ok {
+		return encoding, nil
+	}
+	return "", &ingest.Rejection{
+		Reason:  ingest.ReasonUnsupportedEncoding,
+		Message: fmt.Sprintf("no supported encoding in topic %s", msg.Topic),
+	}
+}
+
+// payloadForLog returns JSON payloads as text and binary ones hex-encoded
+func payloadForLog(encoding string, payload []byte) any {
+	if encoding == ingest.EncodingJSON {
+		return string(payload)
+	}
+	return fmt.Sprintf("%x", payload)
+}
+
+// currentConn returns the connection, or nil before Connect has been called
+func (m *MQTTClient) currentConn() *autopaho.ConnectionManager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conn
+}
+
+// Check reports an error if the broker connection is down
+func (m *MQTTClient) Check(ctx context.Context) error {
+	if !m.connected.Load() {
+		return errors.New("not connected to broker")
+	}
+	return nil
+}
+
+// Stop unsubscribes so the broker stops delivering locations, waits for messages
+// already being handled to finish saving and publishing, then disconnects.
+func (m *MQTTClient) Stop(ctx context.Context) error {
+	conn := m.currentConn()
+	if conn == nil {
+		m.mu.Lock()
+		m.stopped = true
+		m.mu.Unlock()
+		return nil
+	}
+
+	if m.connected.Load() {
+		if _, err := conn.Unsubscribe(ctx, &paho.Unsubscribe{Topics: locationTopics}); err != nil {
+			slog.Warn("Failed to unsubscribe from topics", "topics", locationTopics, "error", err)
+		}
+	}
+
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for in-flight messages: %w", ctx.Err())
+	}
+
+	m.Disconnect()
+	return nil
 }
 
 func (m *MQTTClient) Disconnect() {
-	if m.client != nil && m.client.IsConnected() {
-		m.client.Disconnect(250)
-		log.Println("MQTT client disconnected")
+	conn := m.currentConn()
+	if conn == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	wasConnected := m.connected.Swap(false)
+	if err := conn.Disconnect(ctx); err != nil {
+		slog.Warn("Failed to disconnect MQTT client", "error", err)
+		return
+	}
+	if wasConnected {
+		slog.Info("MQTT client disconnected")
 	}
 }
@@ -3,31 +3,68 @@ package rabbitmq
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
 	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/retry"
+	"transjakarta-fleet/internal/tracing"
 )
 
+// errNotConnected is returned when publishing before Connect has succeeded
+var errNotConnected = errors.New("not connected to RabbitMQ")
+
+var _ events.Backend = (*RabbitMQ)(nil)
+
 type RabbitMQ struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	cfg     *config.Config
+	cfg *config.Config
+
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	closed      chan *amqp.Error // close notification of channel
+	reconnected chan struct{}    // closed and replaced on every successful Connect
+	watchDone   chan struct{}    // closed when Watch returns
+	watchErr    error            // why Watch gave up, set before watchDone is closed
+
+	workerRunning atomic.Bool
 }
 
-func NewRabbitMQ(cfg *config.Config) (*RabbitMQ, error) {
+// NewRabbitMQ returns an unconnected client; publishing fails with an error until Connect succeeds
+func NewRabbitMQ(cfg *config.Config) *RabbitMQ {
+	return &RabbitMQ{
+		cfg:         cfg,
+		reconnected: make(chan struct{}),
+		watchDone:   make(chan struct{}),
+	}
+}
+
+// Connect dials the broker and declares the exchange, queue and binding
+func (r *RabbitMQ) Connect() error {
+	cfg := r.cfg
+
 	conn, err := amqp.Dial(cfg.RabbitMQURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
 	channel, err := conn.Channel()
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return fmt.Errorf("failed to open channel: %w", err)
 	}
 
 	// Declare exchange
@@ -43,7 +80,7 @@ func NewRabbitMQ(cfg *config.Config) (*RabbitMQ, error) {
 	if err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+		return fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
 	// Declare queue
@@ -58,7 +95,7 @@ func NewRabbitMQ(cfg *config.Config) (*RabbitMQ, error) {
 	if err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
 	// Bind queue to exchange
@@ -72,49 +109,213 @@ func NewRabbitMQ(cfg *config.Config) (*RabbitMQ, error) {
 	if err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to bind queue: %w", err)
+		return fmt.Errorf("failed to bind queue: %w", err)
 	}
 
-	log.Println("Successfully connected to RabbitMQ")
+	r.mu.Lock()
+	r.conn = conn
+	r.channel = channel
+	// The channel also closes when its connection does, so this covers both
+	r.closed = channel.NotifyClose(make(chan *amqp.Error, 1))
+	close(r.reconnected)
+	r.reconnected = make(chan struct{})
+	r.mu.Unlock()
 
-	return &RabbitMQ{
-		conn:    conn,
-		channel: channel,
-		cfg:     cfg,
-	}, nil
+	slog.Info("Connected to RabbitMQ", "exchange", cfg.RabbitMQExchange, "queue", cfg.RabbitMQQueue)
+	return nil
+}
+
+// Watch reconnects whenever the connection or channel drops, retrying with the
+// RabbitMQ retry policy, until ctx is done or Close is called. Call it once
+// Connect has succeeded. It returns an error if the broker stays unreachable
+// for the whole policy.
+func (r *RabbitMQ) Watch(ctx context.Context) (err error) {
+	defer func() {
+		r.mu.Lock()
+		r.watchErr = err
+		close(r.watchDone)
+		r.mu.Unlock()
+	}()
+
+	for {
+		r.mu.RLock()
+		conn, closed := r.conn, r.closed
+		r.mu.RUnlock()
+
+		var amqpErr *amqp.Error
+		select {
+		case <-ctx.Done():
+			return nil
+		case amqpErr = <-closed:
+		}
+		if amqpErr == nil {
+			// Closed by Close
+			return nil
+		}
+
+		slog.Warn("RabbitMQ connection lost, reconnecting", "error", amqpErr)
+		// Only the channel may have failed; release the connection before dialing a new one
+		conn.Close()
+
+		err := retry.Do(ctx, "rabbitmq", r.cfg.RabbitMQRetry, func(context.Context) error {
+			return r.Connect()
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to reconnect to RabbitMQ: %w", err)
+		}
+	}
 }
 
-func (r *RabbitMQ) PublishGeofenceEvent(event *models.GeofenceEvent) error {
+// nextChannel waits for Connect to replace old with a new channel. It fails
+// when ctx is done or Watch has stopped reconnecting.
+func (r *RabbitMQ) nextChannel(ctx context.Context, old *amqp.Channel) (*amqp.Channel, error) {
+	for {
+		r.mu.RLock()
+		channel, reconnected := r.channel, r.reconnected
+		r.mu.RUnlock()
+
+		if channel != old && !channel.IsClosed() {
+			return channel, nil
+		}
+
+		select {
+		case <-reconnected:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.watchDone:
+			r.mu.RLock()
+			err := r.watchErr
+			r.mu.RUnlock()
+			if err == nil {
+				err = errors.New("RabbitMQ connection closed")
+			}
+			return nil, err
+		}
+	}
+}
+
+// currentChannel returns the publishing channel, or nil before Connect has succeeded
+func (r *RabbitMQ) currentChannel() *amqp.Channel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.channel
+}
+
+func (r *RabbitMQ) PublishGeofenceEvent(ctx context.Context, event *models.GeofenceEvent) error {
+	if err := r.publish(ctx, events.GeofenceKey(event), event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published geofence event", "vehicle_id", event.VehicleID, "event", event.Event)
+	return nil
+}
+
+// PublishDrivingEvent publishes a driving behaviour event with routing key driving.<event>
+func (r *RabbitMQ) PublishDrivingEvent(ctx context.Context, event *models.DrivingEvent) error {
+	if err := r.publish(ctx, events.DrivingKey(event), event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published driving event", "vehicle_id", event.VehicleID, "event", event.Event, "speed_kmh", event.SpeedKmh)
+	return nil
+}
+
+// PublishPresenceEvent publishes a vehicle_online or vehicle_offline event with routing key vehicle.<state>
+func (r *RabbitMQ) PublishPresenceEvent(ctx context.Context, event *models.PresenceEvent) error {
+	if err := r.publish(ctx, events.PresenceKey(event), event); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Published presence event", "vehicle_id", event.VehicleID, "event", event.Event)
+	return nil
+}
+
+func (r *RabbitMQ) publish(ctx context.Context, routingKey string, event interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, routingKey+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(r.cfg.RabbitMQExchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	channel := r.currentChannel()
+	if channel == nil {
+		return errNotConnected
+	}
+
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Carry the trace context to consumers
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	err = r.channel.PublishWithContext(
+	err = channel.PublishWithContext(
 		ctx,
 		r.cfg.RabbitMQExchange, // exchange
-		"geofence.entry",        // routing key
-		false,                   // mandatory
-		false,                   // immediate
+		routingKey,             // routing key
+		false,                  // mandatory
+		false,                  // immediate
 		amqp.Publishing{
 			ContentType: "application/json",
+			Headers:     headers,
 			Body:        body,
 			Timestamp:   time.Now(),
 		},
 	)
+	metrics.ObservePublish(routingKey, err)
 
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	log.Printf("Published geofence event for vehicle %s", event.VehicleID)
+	return nil
+}
+
+// Check reports an error if the connection or publishing channel has been closed
+func (r *RabbitMQ) Check(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		return errNotConnected
+	}
+	if r.conn.IsClosed() {
+		return errors.New("connection closed")
+	}
+	if r.channel.IsClosed() {
+		return errors.New("channel closed")
+	}
+	return nil
+}
+
+// CheckWorker reports an error if the geofence worker is not consuming
+func (r *RabbitMQ) CheckWorker(ctx context.Context) error {
+	if !r.workerRunning.Load() {
+		return errors.New("geofence worker not running")
+	}
 	return nil
 }
 
 func (r *RabbitMQ) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if r.channel != nil {
 		r.channel.Close()
 	}
@@ -124,39 +325,113 @@ func (r *RabbitMQ) Close() error {
 	return nil
 }
 
-// StartGeofenceWorker starts a worker to consume geofence events
-func StartGeofenceWorker(rabbit *RabbitMQ) {
-	msgs, err := rabbit.channel.Consume(
+// geofenceConsumer is the consumer tag of the geofence worker, used to cancel it
+const geofenceConsumer = "geofence-worker"
+
+// StartGeofenceWorker consumes geofence events until ctx is cancelled, resuming
+// on the new channel after Watch reconnects. On cancellation it stops consuming
+// and returns once deliveries already received have been processed. It returns
+// an error if consuming stops for good while ctx is still live.
+func StartGeofenceWorker(ctx context.Context, rabbit *RabbitMQ) error {
+	channel := rabbit.currentChannel()
+	if channel == nil {
+		return errNotConnected
+	}
+
+	for {
+		err := consumeGeofenceEvents(ctx, rabbit, channel)
+		if ctx.Err() != nil {
+			slog.Info("Geofence worker stopped", "queue", rabbit.cfg.RabbitMQQueue)
+			return nil
+		}
+		if !channel.IsClosed() {
+			// Only a lost channel is recovered by reconnecting
+			if err == nil {
+				err = errors.New("consumer cancelled by broker")
+			}
+			return err
+		}
+
+		slog.Warn("Geofence worker lost its channel, waiting for RabbitMQ to reconnect", "queue", rabbit.cfg.RabbitMQQueue)
+		next, err := rabbit.nextChannel(ctx, channel)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("geofence worker stopped: %w", err)
+		}
+		channel = next
+	}
+}
+
+// consumeGeofenceEvents handles deliveries on channel until it closes or ctx is cancelled
+func consumeGeofenceEvents(ctx context.Context, rabbit *RabbitMQ, channel *amqp.Channel) error {
+	msgs, err := channel.Consume(
 		rabbit.cfg.RabbitMQQueue, // queue
-		"",                        // consumer
-		true,                      // auto-ack
-		false,                     // exclusive
-		false,                     // no-local
-		false,                     // no-wait
-		nil,                       // args
+		geofenceConsumer,         // consumer
+		true,                     // auto-ack
+		false,                    // exclusive
+		false,                    // no-local
+		false,                    // no-wait
+		nil,                      // args
 	)
 	if err != nil {
-		log.Printf("Failed to register consumer: %v", err)
-		return
+		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	log.Println("Geofence worker started, waiting for messages...")
+	slog.Info("Geofence worker started", "queue", rabbit.cfg.RabbitMQQueue)
+	rabbit.workerRunning.Store(true)
+	defer rabbit.workerRunning.Store(false)
 
-	for msg := range msgs {
-		var event models.GeofenceEvent
-		if err := json.Unmarshal(msg.Body, &event); err != nil {
-			log.Printf("Failed to unmarshal geofence event: %v", err)
-			continue
+	stop := context.AfterFunc(ctx, func() {
+		if err := channel.Cancel(geofenceConsumer, false); err != nil {
+			slog.Warn("Failed to cancel geofence consumer", "error", err)
 		}
+	})
+	defer stop()
 
-		log.Printf("Received geofence event: Vehicle %s entered geofence at (%.6f, %.6f) at timestamp %d",
-			event.VehicleID,
-			event.Location.Latitude,
-			event.Location.Longitude,
-			event.Timestamp,
-		)
+	for msg := range msgs {
+		handleGeofenceEvent(rabbit.cfg.RabbitMQQueue, msg)
+	}
+	return nil
+}
+
+// handleGeofenceEvent processes one geofence event, continuing the trace of the location that raised it
+func handleGeofenceEvent(queue string, msg amqp.Delivery) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg.Headers))
+	ctx, span := tracing.Start(ctx, queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingDestinationName(queue),
+			semconv.MessagingRabbitmqDestinationRoutingKey(msg.RoutingKey),
+		),
+	)
+	defer span.End()
+
+	logger := slog.Default()
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		logger = logger.With("trace_id", traceID)
+	}
 
-		// Here you can add additional processing logic
-		// For example: send notification, update database, trigger alerts, etc.
+	var event models.GeofenceEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		logger.Warn("Failed to unmarshal geofence event", "error", err)
+		tracing.RecordError(span, err)
+		metrics.RabbitMQConsumed.WithLabelValues(queue, "invalid").Inc()
+		return
 	}
+	metrics.RabbitMQConsumed.WithLabelValues(queue, "success").Inc()
+	span.SetAttributes(attribute.String("vehicle.id", event.VehicleID))
+
+	logger.Info("Received geofence event",
+		"vehicle_id", event.VehicleID,
+		"latitude", event.Location.Latitude,
+		"longitude", event.Location.Longitude,
+		"timestamp", event.Timestamp,
+	)
+
+	// Here you can add additional processing logic
+	// For example: send notification, update database, trigger alerts, etc.
 }
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleTimeout is how long an untouched bucket is kept before it is dropped
+const idleTimeout = 10 * time.Minute
+
+// Limiter is a token-bucket rate limiter with one bucket per client key
+type Limiter struct {
+	rate    float64 // tokens per second
+	burst   int
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result describes the outcome of a request against a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if Allowed
+	RetryAfter time.Duration
+}
+
+// New returns a Limiter refilling rate tokens per second up to burst tokens
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is available
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+
+	return result
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < idleTimeout {
+		return
+	}
+	l.swept = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
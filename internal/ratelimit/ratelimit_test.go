@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	type request struct {
+		key           string
+		after         time.Duration // since start
+		wantAllowed   bool
+		wantRemaining int
+	}
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		requests []request
+	}{
+		{
+			name:  "burst then empty",
+			rate:  1,
+			burst: 3,
+			requests: []request{
+				{key: "a", wantAllowed: true, wantRemaining: 2},
+				{key: "a", wantAllowed: true, wantRemaining: 1},
+				{key: "a", wantAllowed: true, wantRemaining: 0},
+				{key: "a", wantAllowed: false, wantRemaining: 0},
+			},
+		},
+		{
+			name:  "refills at the rate",
+			rate:  2,
+			burst: 1,
+			requests: []request{
+				{key: "a", wantAllowed: true},
+				{key: "a", after: 100 * time.Millisecond, wantAllowed: false},
+				{key: "a", after: 500 * time.Millisecond, wantAllowed: true},
+			},
+		},
+		{
+			name:  "refill is capped at the burst",
+			rate:  10,
+			burst: 2,
+			requests: []request{
+				{key: "a", wantAllowed: true, wantRemaining: 1},
+				{key: "a", after: time.Hour, wantAllowed: true, wantRemaining: 1},
+			},
+		},
+		{
+			name:  "keys have their own buckets",
+			rate:  1,
+			burst: 1,
+			requests: []request{
+				{key: "a", wantAllowed: true},
+				{key: "a", wantAllowed: false},
+				{key: "b", wantAllowed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(tt.rate, tt.burst)
+			for i, req := range tt.requests {
+				result := limiter.Allow(req.key, start.Add(req.after))
+				if result.Allowed != req.wantAllowed {
+					t.Fatalf("request %d: Allowed = %v, want %v", i, result.Allowed, req.wantAllowed)
+				}
+				if result.Remaining != req.wantRemaining {
+					t.Errorf("request %d: Remaining = %d, want %d", i, result.Remaining, req.wantRemaining)
+				}
+				if result.Limit != tt.burst {
+					t.Errorf("request %d: Limit = %d, want %d", i, result.Limit, tt.burst)
+				}
+				if result.Allowed != (result.RetryAfter == 0) {
+					t.Errorf("request %d: RetryAfter = %s with Allowed = %v", i, result.RetryAfter, result.Allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	limiter := New(0.5, 1)
+	now := time.Unix(1700000000, 0)
+
+	limiter.Allow("a", now)
+	result := limiter.Allow("a", now)
+	if result.Allowed {
+		t.Fatal("second request allowed, want it limited")
+	}
+	if result.RetryAfter != 2*time.Second {
+		t.Errorf("RetryAfter = %s, want 2s", result.RetryAfter)
+	}
+	if result.Reset != 2*time.Second {
+		t.Errorf("Reset = %s, want 2s", result.Reset)
+	}
+
+	if result := limiter.Allow("a", now.Add(result.RetryAfter)); !result.Allowed {
+		t.Error("request after RetryAfter limited, want it allowed")
+	}
+}
@@ -0,0 +1,266 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+	"transjakarta-fleet/internal/repository/memory"
+)
+
+// Store serves reads from memory and appends every change to a journal file,
+// one JSON record per line, which is replayed when the store is opened. It
+// lets a single process keep its history across restarts without a database.
+type Store struct {
+	*memory.Store
+
+	path string
+
+	mu     sync.Mutex // orders journal records like the changes they describe
+	file   *os.File
+	offset int64          // end of the last complete journal record
+	hashes map[int]string // API key hashes by ID, to journal revocations
+}
+
+// record is a journal line. Exactly one of its fields is set; vehicle and
+// key records replace any earlier record with the same vehicle or key ID.
+type record struct {
+	Location *models.VehicleLocation `json:"location,omitempty"`
+	Vehicle  *models.Vehicle         `json:"vehicle,omitempty"`
+	Key      *models.APIKey          `json:"api_key,omitempty"`
+	KeyHash  string                  `json:"key_hash,omitempty"`
+}
+
+var _ repository.Store = (*Store)(nil)
+
+// New creates a store journaling to path. Call Open before using it.
+func New(path string) *Store {
+	return &Store{
+		Store:  memory.New(),
+		path:   path,
+		hashes: make(map[int]string),
+	}
+}
+
+// Open creates the journal if needed and replays it
+func (s *Store) Open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open storage file: %w", err)
+	}
+
+	count, offset, err := s.replay(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.offset = offset
+	slog.Info("Opened file storage", "path", s.path, "records", count)
+	return nil
+}
+
+// replay loads the journal and leaves file positioned at its end. A last line
+// without a newline is the remains of an interrupted write and is truncated.
+func (s *Store) replay(file *os.File) (int, int64, error) {
+	reader := bufio.NewReader(file)
+	var offset int64
+	count := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				slog.Warn("Discarding incomplete storage record", "path", s.path, "offset", offset)
+				if err := file.Truncate(offset); err != nil {
+					return 0, 0, fmt.Errorf("failed to truncate storage file: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read storage file: %w", err)
+		}
+
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return 0, 0, fmt.Errorf("failed to decode storage record at offset %d: %w", offset-int64(len(line)), err)
+		}
+		s.apply(r)
+		count++
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek storage file: %w", err)
+	}
+	return count, offset, nil
+}
+
+func (s *Store) apply(r record) {
+	switch {
+	case r.Location != nil:
+		s.Store.RestoreLocation(*r.Location)
+	case r.Vehicle != nil:
+		s.Store.RestoreVehicle(*r.Vehicle)
+	case r.Key != nil:
+		s.hashes[r.Key.ID] = r.KeyHash
+		s.Store.RestoreKey(*r.Key, r.KeyHash)
+	}
+}
+
+// append writes a record to the journal. Changes are applied to memory only
+// once their record is written, and a failed or short write is truncated so
+// the next record starts on a line of its own.
+func (s *Store) append(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode storage record: %w", err)
+	}
+	line = append(line, '\n')
+
+	n, err := s.file.Write(line)
+	if err != nil {
+		if n > 0 {
+			s.rewind()
+		}
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+	s.offset += int64(n)
+	return nil
+}
+
+// rewind drops a partly written record from the end of the journal
+func (s *Store) rewind() {
+	if err := s.file.Truncate(s.offset); err != nil {
+		slog.Error("Failed to truncate incomplete storage record", "path", s.path, "offset", s.offset, "error", err)
+		return
+	}
+	if _, err := s.file.Seek(s.offset, io.SeekStart); err != nil {
+		slog.Error("Failed to seek storage file", "path", s.path, "offset", s.offset, "error", err)
+	}
+}
+
+func (s *Store) SaveLocation(ctx context.Context, location *models.VehicleLocation, defaultOperatorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *location
+	stored.OperatorID = defaultOperatorID
+	if vehicle, ok := s.Store.Vehicle(location.VehicleID); ok {
+		stored.OperatorID = vehicle.OperatorID
+	}
+
+	if err := s.append(record{Location: &stored}); err != nil {
+		return err
+	}
+	s.Store.RestoreLocation(stored)
+	location.OperatorID = stored.OperatorID
+	return nil
+}
+
+func (s *Store) RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (*models.Vehicle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	vehicle, ok := s.Store.Vehicle(vehicleID)
+	if !ok {
+		vehicle = models.Vehicle{VehicleID: vehicleID, CreatedAt: now}
+	}
+	vehicle.OperatorID = operatorID
+	vehicle.UpdatedAt = now
+
+	if err := s.append(record{Vehicle: &vehicle}); err != nil {
+		return nil, err
+	}
+	s.Store.RestoreVehicle(vehicle)
+	return &vehicle, nil
+}
+
+func (s *Store) CreateKey(ctx context.Context, key *models.APIKey, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.Store.ListKeys(ctx, "")
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(keys) > 0 {
+		id = keys[len(keys)-1].ID + 1
+	}
+
+	// Never write the plaintext key
+	stored := *key
+	stored.Key = ""
+	stored.ID = id
+	stored.CreatedAt = time.Now()
+
+	if err := s.append(record{Key: &stored, KeyHash: hash}); err != nil {
+		return err
+	}
+	s.hashes[id] = hash
+	s.Store.RestoreKey(stored, hash)
+	key.ID = stored.ID
+	key.CreatedAt = stored.CreatedAt
+	return nil
+}
+
+func (s *Store) RevokeKey(ctx context.Context, operatorID string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.Store.ListKeys(ctx, operatorID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.ID != id || key.RevokedAt != nil {
+			continue
+		}
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := s.append(record{Key: key, KeyHash: s.hashes[id]}); err != nil {
+			return err
+		}
+		s.Store.RestoreKey(*key, s.hashes[id])
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+// Close flushes the journal to disk and closes it
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to sync storage file: %w", err)
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+// Store keeps locations, vehicles and API keys in process memory. Nothing
+// survives a restart; it backs tests and single-process deployments.
+type Store struct {
+	mu        sync.RWMutex
+	locations map[string][]models.VehicleLocation // by vehicle, in timestamp order
+	vehicles  map[string]models.Vehicle
+	keys      []apiKey
+	lastKeyID int
+}
+
+type apiKey struct {
+	key  models.APIKey
+	hash string
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		locations: make(map[string][]models.VehicleLocation),
+		vehicles:  make(map[string]models.Vehicle),
+	}
+}
+
+func (s *Store) SaveLocation(ctx context.Context, location *models.VehicleLocation, defaultOperatorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	location.OperatorID = defaultOperatorID
+	if vehicle, ok := s.vehicles[location.VehicleID]; ok {
+		location.OperatorID = vehicle.OperatorID
+	}
+
+	s.insertLocation(*location)
+
+	return nil
+}
+
+func (s *Store) LastLocation(ctx context.Context, operatorID, vehicleID string) (*models.VehicleLocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if location := latest(s.locations[vehicleID], operatorID); location != nil {
+		return location, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Store) LatestLocations(ctx context.Context, operatorID string) ([]*models.VehicleLocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var locations []*models.VehicleLocation
+	for _, vehicleID := range sortedKeys(s.locations) {
+		if location := latest(s.locations[vehicleID], operatorID); location != nil {
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+func (s *Store) StreamHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) error {
+	s.mu.RLock()
+	locations := between(s.locations[vehicleID], operatorID, startTime, endTime)
+	s.mu.RUnlock()
+
+	return stream(ctx, locations, fn)
+}
+
+func (s *Store) StreamFleetHistory(ctx context.Context, operatorID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) error {
+	s.mu.RLock()
+	var locations []*models.VehicleLocation
+	for _, vehicleID := range sortedKeys(s.locations) {
+		locations = append(locations, between(s.locations[vehicleID], operatorID, startTime, endTime)...)
+	}
+	s.mu.RUnlock()
+
+	return stream(ctx, locations, fn)
+}
+
+func (s *Store) RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (*models.Vehicle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	vehicle, ok := s.vehicles[vehicleID]
+	if !ok {
+		vehicle = models.Vehicle{VehicleID: vehicleID, CreatedAt: now}
+	}
+	vehicle.OperatorID = operatorID
+	vehicle.UpdatedAt = now
+	s.vehicles[vehicleID] = vehicle
+
+	return &vehicle, nil
+}
+
+func (s *Store) ListVehicles(ctx context.Context, operatorID string) ([]*models.Vehicle, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vehicles := []*models.Vehicle{}
+	for _, vehicleID := range sortedKeys(s.vehicles) {
+		vehicle := s.vehicles[vehicleID]
+		if operatorID == "" || vehicle.OperatorID == operatorID {
+			vehicles = append(vehicles, &vehicle)
+		}
+	}
+	return vehicles, nil
+}
+
+func (s *Store) CreateKey(ctx context.Context, key *models.APIKey, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastKeyID++
+	key.ID = s.lastKeyID
+	key.CreatedAt = time.Now()
+
+	stored := *key
+	stored.Key = ""
+	s.keys = append(s.keys, apiKey{key: stored, hash: hash})
+
+	return nil
+}
+
+func (s *Store) ListKeys(ctx context.Context, operatorID string) ([]*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []*models.APIKey{}
+	for _, k := range s.keys {
+		if operatorID == "" || k.key.OperatorID == operatorID {
+			key := k.key
+			keys = append(keys, &key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *Store) RevokeKey(ctx context.Context, operatorID string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		key := &s.keys[i].key
+		if key.ID != id || key.RevokedAt != nil || (operatorID != "" && key.OperatorID != operatorID) {
+			continue
+		}
+		now := time.Now()
+		key.RevokedAt = &now
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (s *Store) FindKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.hash == hash && k.key.RevokedAt == nil {
+			key := k.key
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// Vehicle returns the registration of a vehicle, if any
+func (s *Store) Vehicle(vehicleID string) (models.Vehicle, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vehicle, ok := s.vehicles[vehicleID]
+	return vehicle, ok
+}
+
+// RestoreLocation adds a previously saved location as is, without resolving its operator
+func (s *Store) RestoreLocation(location models.VehicleLocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertLocation(location)
+}
+
+// RestoreVehicle adds or replaces a vehicle registration as is
+func (s *Store) RestoreVehicle(vehicle models.Vehicle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.vehicles[vehicle.VehicleID] = vehicle
+}
+
+// RestoreKey adds or replaces the API key with the ID of key, keeping its
+// creation and revocation times
+func (s *Store) RestoreKey(key models.APIKey, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.Key = ""
+	if key.ID > s.lastKeyID {
+		s.lastKeyID = key.ID
+	}
+	for i := range s.keys {
+		if s.keys[i].key.ID == key.ID {
+			s.keys[i] = apiKey{key: key, hash: hash}
+			return
+		}
+	}
+	s.keys = append(s.keys, apiKey{key: key, hash: hash})
+}
+
+// insertLocation inserts after any location with the same timestamp to keep
+// arrival order among equals
+func (s *Store) insertLocation(location models.VehicleLocation) {
+	history := s.locations[location.VehicleID]
+	i := sort.Search(len(history), func(i int) bool { return history[i].Timestamp > location.Timestamp })
+	history = append(history, models.VehicleLocation{})
+	copy(history[i+1:], history[i:])
+	history[i] = location
+	s.locations[location.VehicleID] = history
+}
+
+// latest returns a copy of the newest plausible location of an operator in a history, or nil
+func latest(history []models.VehicleLocation, operatorID string) *models.VehicleLocation {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Implausible == "" && (operatorID == "" || history[i].OperatorID == operatorID) {
+			location := history[i]
+			return &location
+		}
+	}
+	return nil
+}
+
+// between returns copies of the locations of an operator within a time range
+func between(history []models.VehicleLocation, operatorID string, startTime, endTime int64) []*models.VehicleLocation {
+	start := sort.Search(len(history), func(i int) bool { return history[i].Timestamp >= startTime })
+
+	var locations []*models.VehicleLocation
+	for _, location := range history[start:] {
+		if location.Timestamp > endTime {
+			break
+		}
+		if operatorID == "" || location.OperatorID == operatorID {
+			location := location
+			locations = append(locations, &location)
+		}
+	}
+	return locations
+}
+
+// stream calls fn outside the lock so a slow consumer does not block writers
+func stream(ctx context.Context, locations []*models.VehicleLocation, fn func(*models.VehicleLocation) error) error {
+	for _, location := range locations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
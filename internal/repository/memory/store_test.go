@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+var _ repository.Store = (*Store)(nil)
+
+// timestamps lists each location as vehicle@last digit of its timestamp
+func timestamps(locations []*models.VehicleLocation) []string {
+	got := []string{}
+	for _, location := range locations {
+		got = append(got, fmt.Sprintf("%s@%d", location.VehicleID, location.Timestamp%10))
+	}
+	return got
+}
+
+func TestStoreLocations(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	if _, err := store.RegisterVehicle(ctx, "B1", "op1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved out of order, B2 unregistered
+	for _, location := range []models.VehicleLocation{
+		{VehicleID: "B1", Timestamp: 1000000003},
+		{VehicleID: "B1", Timestamp: 1000000001},
+		{VehicleID: "B2", Timestamp: 1000000002},
+		{VehicleID: "B1", Timestamp: 1000000002},
+	} {
+		if err := store.SaveLocation(ctx, &location, "super"); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"B1": "op1", "B2": "super"}[location.VehicleID]
+		if location.OperatorID != want {
+			t.Errorf("%s saved under operator %q, want %q", location.VehicleID, location.OperatorID, want)
+		}
+	}
+
+	history := func(operatorID, vehicleID string, start, end int64) []string {
+		var locations []*models.VehicleLocation
+		collect := func(location *models.VehicleLocation) error {
+			locations = append(locations, location)
+			return nil
+		}
+		var err error
+		if vehicleID == "" {
+			err = store.StreamFleetHistory(ctx, operatorID, start, end, collect)
+		} else {
+			err = store.StreamHistory(ctx, operatorID, vehicleID, start, end, collect)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return timestamps(locations)
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"history in timestamp order", history("", "B1", 0, 2000000000), []string{"B1@1", "B1@2", "B1@3"}},
+		{"history bounds are inclusive", history("", "B1", 1000000002, 1000000003), []string{"B1@2", "B1@3"}},
+		{"history of another operator", history("op2", "B1", 0, 2000000000), []string{}},
+		{"fleet history by vehicle", history("", "", 1000000002, 1000000002), []string{"B1@2", "B2@2"}},
+		{"fleet history of an operator", history("op1", "", 0, 2000000000), []string{"B1@1", "B1@2", "B1@3"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	last, err := store.LastLocation(ctx, "op1", "B1")
+	if err != nil || last.Timestamp != 1000000003 {
+		t.Errorf("LastLocation(op1, B1) = %+v, %v, want the location at 3", last, err)
+	}
+	if _, err := store.LastLocation(ctx, "op1", "B2"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("LastLocation(op1, B2) error = %v, want ErrNotFound", err)
+	}
+
+	latest, err := store.LatestLocations(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := timestamps(latest), []string{"B1@3", "B2@2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LatestLocations() = %v, want %v", got, want)
+	}
+}
+
+func TestStoreStreamStops(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	for i := int64(0); i < 3; i++ {
+		store.RestoreLocation(models.VehicleLocation{VehicleID: "B1", Timestamp: i})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.StreamHistory(ctx, "", "B1", 0, 10, func(*models.VehicleLocation) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("StreamHistory() = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.StreamHistory(cancelled, "", "B1", 0, 10, func(*models.VehicleLocation) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("StreamHistory() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestStoreKeys(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	for _, key := range []*models.APIKey{
+		{Name: "a", OperatorID: "op1"},
+		{Name: "b", OperatorID: "op2"},
+	} {
+		if err := store.CreateKey(ctx, key, "hash-"+key.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RevokeKey(ctx, "op1", 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoking another operator's key = %v, want ErrNotFound", err)
+	}
+	if err := store.RevokeKey(ctx, "op2", 2); err != nil {
+		t.Fatalf("RevokeKey() = %v", err)
+	}
+	if err := store.RevokeKey(ctx, "", 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoking a revoked key = %v, want ErrNotFound", err)
+	}
+
+	if key, err := store.FindKey(ctx, "hash-a"); err != nil || key.Name != "a" {
+		t.Errorf("FindKey(hash-a) = %+v, %v, want key a", key, err)
+	}
+	if _, err := store.FindKey(ctx, "hash-b"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("FindKey() of a revoked key = %v, want ErrNotFound", err)
+	}
+
+	keys, err := store.ListKeys(ctx, "op2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Name != "b" || keys[0].RevokedAt == nil {
+		t.Errorf("ListKeys(op2) = %+v, want the revoked key b", keys)
+	}
+}
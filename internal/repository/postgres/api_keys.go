@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+func (s *Store) CreateKey(ctx context.Context, key *models.APIKey, hash string) (err error) {
+	query := `
+		INSERT INTO api_keys (name, role, prefix, key_hash, operator_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "INSERT", "api_keys")
+	defer done(&err)
+
+	err = s.db.QueryRowContext(ctx, query, key.Name, key.Role, key.Prefix, hash, key.OperatorID).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return nil
+}
+
+// Keys created before tenancy was introduced have no operator. They are read
+// with an empty OperatorID and only listed and revoked across all operators.
+func (s *Store) ListKeys(ctx context.Context, operatorID string) (_ []*models.APIKey, err error) {
+	query := `
+		SELECT id, name, role, COALESCE(operator_id, ''), prefix, created_at, revoked_at
+		FROM api_keys
+		WHERE $1 = '' OR operator_id = $1
+		ORDER BY id ASC
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "api_keys")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, operatorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key := &models.APIKey{}
+		if err := rows.Scan(
+			&key.ID,
+			&key.Name,
+			&key.Role,
+			&key.OperatorID,
+			&key.Prefix,
+			&key.CreatedAt,
+			&key.RevokedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *Store) RevokeKey(ctx context.Context, operatorID string, id int) (err error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL AND ($2 = '' OR operator_id = $2)
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "UPDATE", "api_keys")
+	defer done(&err)
+
+	result, err := s.db.ExecContext(ctx, query, id, operatorID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) FindKey(ctx context.Context, hash string) (_ *models.APIKey, err error) {
+	query := `
+		SELECT id, name, role, COALESCE(operator_id, ''), prefix, created_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "api_keys")
+	defer done(&err)
+
+	key := &models.APIKey{}
+	err = s.db.QueryRowContext(ctx, query, hash).Scan(
+		&key.ID,
+		&key.Name,
+		&key.Role,
+		&key.OperatorID,
+		&key.Prefix,
+		&key.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	return key, nil
+}
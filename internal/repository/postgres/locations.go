@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+func (s *Store) SaveLocation(ctx context.Context, location *models.VehicleLocation, defaultOperatorID string) (err error) {
+	query := `
+		INSERT INTO vehicle_locations (vehicle_id, latitude, longitude, timestamp, speed, operator_id, late, implausible)
+		VALUES ($1, $2, $3, $4, $5, COALESCE((SELECT operator_id FROM vehicles WHERE vehicle_id = $1), $6), $7, NULLIF($8, ''))
+		RETURNING operator_id
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "INSERT", "vehicle_locations")
+	defer done(&err)
+
+	err = s.db.QueryRowContext(
+		ctx,
+		query,
+		location.VehicleID,
+		location.Latitude,
+		location.Longitude,
+		location.Timestamp,
+		location.Speed,
+		defaultOperatorID,
+		location.Late,
+		location.Implausible,
+	).Scan(&location.OperatorID)
+	if err != nil {
+		return fmt.Errorf("failed to save location: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) LastLocation(ctx context.Context, operatorID, vehicleID string) (_ *models.VehicleLocation, err error) {
+	query := `
+		SELECT vehicle_id, latitude, longitude, timestamp, speed, COALESCE(operator_id, ''), late, COALESCE(implausible, '')
+		FROM vehicle_locations
+		WHERE vehicle_id = $1 AND ($2 = '' OR operator_id = $2) AND implausible IS NULL
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "vehicle_locations")
+	defer done(&err)
+
+	location := &models.VehicleLocation{}
+	err = s.db.QueryRowContext(ctx, query, vehicleID, operatorID).Scan(
+		&location.VehicleID,
+		&location.Latitude,
+		&location.Longitude,
+		&location.Timestamp,
+		&location.Speed,
+		&location.OperatorID,
+		&location.Late,
+		&location.Implausible,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+
+	return location, nil
+}
+
+func (s *Store) LatestLocations(ctx context.Context, operatorID string) (_ []*models.VehicleLocation, err error) {
+	query := `
+		SELECT DISTINCT ON (vehicle_id) vehicle_id, latitude, longitude, timestamp, speed, COALESCE(operator_id, ''), late, COALESCE(implausible, '')
+		FROM vehicle_locations
+		WHERE ($1 = '' OR operator_id = $1) AND implausible IS NULL
+		ORDER BY vehicle_id, timestamp DESC
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "vehicle_locations")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, operatorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []*models.VehicleLocation
+	err = scanLocations(rows, func(location *models.VehicleLocation) error {
+		locations = append(locations, location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
+
+func (s *Store) StreamHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) (err error) {
+	query := `
+		SELECT vehicle_id, latitude, longitude, timestamp, speed, COALESCE(operator_id, ''), late, COALESCE(implausible, '')
+		FROM vehicle_locations
+		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp <= $3 AND ($4 = '' OR operator_id = $4)
+		ORDER BY timestamp ASC
+	`
+
+	// Streaming exports can legitimately take longer than a regular query
+	ctx, done := startQuery(ctx, s.cfg.HistoryQueryTimeout, "SELECT", "vehicle_locations")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, vehicleID, startTime, endTime, operatorID)
+	if err != nil {
+		return fmt.Errorf("failed to query location history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanLocations(rows, fn)
+}
+
+func (s *Store) StreamFleetHistory(ctx context.Context, operatorID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) (err error) {
+	query := `
+		SELECT vehicle_id, latitude, longitude, timestamp, speed, COALESCE(operator_id, ''), late, COALESCE(implausible, '')
+		FROM vehicle_locations
+		WHERE timestamp >= $1 AND timestamp <= $2 AND ($3 = '' OR operator_id = $3)
+		ORDER BY vehicle_id, timestamp ASC
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.HistoryQueryTimeout, "SELECT", "vehicle_locations")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, startTime, endTime, operatorID)
+	if err != nil {
+		return fmt.Errorf("failed to query fleet locations: %w", err)
+	}
+	defer rows.Close()
+
+	return scanLocations(rows, fn)
+}
+
+// scanLocations calls fn for each row of a query selecting the full location columns
+func scanLocations(rows *sql.Rows, fn func(*models.VehicleLocation) error) error {
+	for rows.Next() {
+		location := &models.VehicleLocation{}
+		if err := rows.Scan(
+			&location.VehicleID,
+			&location.Latitude,
+			&location.Longitude,
+			&location.Timestamp,
+			&location.Speed,
+			&location.OperatorID,
+			&location.Late,
+			&location.Implausible,
+		); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
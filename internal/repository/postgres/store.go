@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/repository"
+	"transjakarta-fleet/internal/tracing"
+)
+
+// Store keeps locations, vehicles and API keys in PostgreSQL
+type Store struct {
+	db  *sql.DB
+	cfg *config.Config
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func New(db *sql.DB, cfg *config.Config) *Store {
+	return &Store{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// startQuery bounds a database operation by timeout and traces it as a client span
+// named like "INSERT vehicle_locations". The returned function records *err on the
+// span, ends it and releases the timeout; it is meant to be deferred:
+//
+//	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "vehicles")
+//	defer done(&err)
+func startQuery(ctx context.Context, timeout time.Duration, operation, table string) (context.Context, func(err *error)) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, span := tracing.Start(ctx, operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable(table),
+		),
+	)
+
+	return ctx, func(err *error) {
+		tracing.RecordError(span, *err)
+		span.End()
+		cancel()
+	}
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+// TimescaleStore is a Store for a database migrated with
+// database.RunTimescaleMigrations. Writes and history go to the
+// vehicle_locations hypertable; fleet-wide latest positions come from the
+// recent buckets of the hourly continuous aggregate instead of scanning every chunk.
+type TimescaleStore struct {
+	*Store
+}
+
+// latestLocationWindow bounds the aggregate buckets read for latest positions.
+// Registered vehicles silent for longer are looked up in the hypertable one by
+// one; unregistered ones drop out of the list.
+const latestLocationWindow = 24 * time.Hour
+
+var _ repository.Store = (*TimescaleStore)(nil)
+
+func NewTimescale(db *sql.DB, cfg *config.Config) *TimescaleStore {
+	return &TimescaleStore{Store: New(db, cfg)}
+}
+
+func (s *TimescaleStore) LatestLocations(ctx context.Context, operatorID string) (_ []*models.VehicleLocation, err error) {
+	// The aggregate leaves out implausible locations and has no late column;
+	// a vehicle's newest location is never late
+	query := `
+		WITH recent AS (
+			SELECT DISTINCT ON (vehicle_id) vehicle_id, latitude, longitude, last_timestamp, speed, operator_id
+			FROM vehicle_locations_hourly
+			WHERE bucket >= unix_now() - $2 AND ($1 = '' OR operator_id = $1)
+			ORDER BY vehicle_id, last_timestamp DESC
+		)
+		SELECT vehicle_id, latitude, longitude, last_timestamp, speed, COALESCE(operator_id, ''), FALSE, ''
+		FROM recent
+		UNION ALL
+		SELECT l.vehicle_id, l.latitude, l.longitude, l.timestamp, l.speed, COALESCE(l.operator_id, ''), l.late, COALESCE(l.implausible, '')
+		FROM vehicles v
+		CROSS JOIN LATERAL (
+			SELECT * FROM vehicle_locations
+			WHERE vehicle_id = v.vehicle_id AND ($1 = '' OR operator_id = $1) AND implausible IS NULL
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) l
+		WHERE v.vehicle_id NOT IN (SELECT vehicle_id FROM recent)
+		ORDER BY 1
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "vehicle_locations_hourly")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, operatorID, int64(latestLocationWindow.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []*models.VehicleLocation
+	err = scanLocations(rows, func(location *models.VehicleLocation) error {
+		locations = append(locations, location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
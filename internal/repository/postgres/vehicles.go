@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"transjakarta-fleet/internal/models"
+)
+
+func (s *Store) RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (_ *models.Vehicle, err error) {
+	query := `
+		INSERT INTO vehicles (vehicle_id, operator_id)
+		VALUES ($1, $2)
+		ON CONFLICT (vehicle_id) DO UPDATE SET operator_id = EXCLUDED.operator_id, updated_at = CURRENT_TIMESTAMP
+		RETURNING vehicle_id, operator_id, created_at, updated_at
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "INSERT", "vehicles")
+	defer done(&err)
+
+	vehicle := &models.Vehicle{}
+	err = s.db.QueryRowContext(ctx, query, vehicleID, operatorID).Scan(
+		&vehicle.VehicleID,
+		&vehicle.OperatorID,
+		&vehicle.CreatedAt,
+		&vehicle.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register vehicle: %w", err)
+	}
+
+	return vehicle, nil
+}
+
+func (s *Store) ListVehicles(ctx context.Context, operatorID string) (_ []*models.Vehicle, err error) {
+	query := `
+		SELECT vehicle_id, operator_id, created_at, updated_at
+		FROM vehicles
+		WHERE $1 = '' OR operator_id = $1
+		ORDER BY vehicle_id ASC
+	`
+
+	ctx, done := startQuery(ctx, s.cfg.QueryTimeout, "SELECT", "vehicles")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, operatorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicles: %w", err)
+	}
+	defer rows.Close()
+
+	vehicles := []*models.Vehicle{}
+	for rows.Next() {
+		vehicle := &models.Vehicle{}
+		if err := rows.Scan(
+			&vehicle.VehicleID,
+			&vehicle.OperatorID,
+			&vehicle.CreatedAt,
+			&vehicle.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return vehicles, nil
+}
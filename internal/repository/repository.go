@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"transjakarta-fleet/internal/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// LocationRepository stores reported vehicle locations.
+// An empty operatorID in a query matches locations of every operator.
+type LocationRepository interface {
+	// SaveLocation stores a location under the operator its vehicle is registered to,
+	// or defaultOperatorID if it is not registered, and sets location.OperatorID accordingly
+	SaveLocation(ctx context.Context, location *models.VehicleLocation, defaultOperatorID string) error
+
+	// LastLocation returns the most recent location of a vehicle, or ErrNotFound.
+	// Locations flagged implausible are history only and never the latest.
+	LastLocation(ctx context.Context, operatorID, vehicleID string) (*models.VehicleLocation, error)
+
+	// LatestLocations returns the most recent location of every vehicle, ordered by vehicle ID,
+	// passing over implausible locations like LastLocation
+	LatestLocations(ctx context.Context, operatorID string) ([]*models.VehicleLocation, error)
+
+	// StreamHistory calls fn for each location of a vehicle within a time range in timestamp order.
+	// Iteration stops at the first error returned by fn.
+	StreamHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) error
+
+	// StreamFleetHistory is StreamHistory over every vehicle, ordered by vehicle ID and then timestamp
+	StreamFleetHistory(ctx context.Context, operatorID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) error
+}
+
+// VehicleRepository stores the assignment of vehicles to operators
+type VehicleRepository interface {
+	// RegisterVehicle assigns a vehicle to an operator, replacing any earlier assignment
+	RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (*models.Vehicle, error)
+
+	// ListVehicles returns registered vehicles ordered by vehicle ID
+	ListVehicles(ctx context.Context, operatorID string) ([]*models.Vehicle, error)
+}
+
+// APIKeyRepository stores API keys by the hash of their secret
+type APIKeyRepository interface {
+	// CreateKey stores a key and sets its ID and creation time
+	CreateKey(ctx context.Context, key *models.APIKey, hash string) error
+
+	// ListKeys returns keys including revoked ones, ordered by ID
+	ListKeys(ctx context.Context, operatorID string) ([]*models.APIKey, error)
+
+	// RevokeKey revokes an active key, or returns ErrNotFound
+	RevokeKey(ctx context.Context, operatorID string, id int) error
+
+	// FindKey returns the active key with the given hash, or ErrNotFound.
+	// Keys without an operator are returned with an empty OperatorID.
+	FindKey(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// Store is a storage backend holding every repository
+type Store interface {
+	LocationRepository
+	VehicleRepository
+	APIKeyRepository
+}
@@ -0,0 +1,60 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"transjakarta-fleet/internal/config"
+)
+
+// Do calls fn until it succeeds, waiting with exponential backoff and jitter between attempts.
+// It gives up after policy.MaxAttempts attempts (never when zero) or when ctx is done.
+func Do(ctx context.Context, name string, policy config.RetryPolicy, fn func(ctx context.Context) error) error {
+	delay := policy.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("Dependency available", "dependency", name, "attempts", attempt)
+			}
+			return nil
+		}
+
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return fmt.Errorf("%s unavailable after %d attempts: %w", name, attempt, err)
+		}
+
+		wait := jitter(delay)
+		slog.Warn("Dependency unavailable, retrying",
+			"dependency", name,
+			"attempt", attempt,
+			"retry_in", wait.Round(time.Millisecond).String(),
+			"error", err,
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > policy.MaxInterval {
+			delay = policy.MaxInterval
+		}
+	}
+}
+
+// jitter spreads retries over [d/2, d) so instances restarted together do not retry in lockstep
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+// apiKeyPrefix marks generated keys so they are easy to recognise in logs and secret scanners
+const apiKeyPrefix = "tjf_"
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+type APIKeyService struct {
+	keys repository.APIKeyRepository
+	cfg  *config.Config
+}
+
+func NewAPIKeyService(keys repository.APIKeyRepository, cfg *config.Config) *APIKeyService {
+	return &APIKeyService{
+		keys: keys,
+		cfg:  cfg,
+	}
+}
+
+// CreateKey generates a new API key for an operator. The plaintext key is only available in the returned value.
+func (s *APIKeyService) CreateKey(ctx context.Context, name string, role auth.Role, operatorID string) (*models.APIKey, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &models.APIKey{
+		Name:       name,
+		Role:       string(role),
+		OperatorID: operatorID,
+		Prefix:     plaintext[:len(apiKeyPrefix)+4],
+		Key:        plaintext,
+	}
+
+	if err := s.keys.CreateKey(ctx, key, hashAPIKey(plaintext)); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ListKeys returns the API keys of an operator, including revoked ones, without their secrets.
+// An empty operatorID lists keys of all operators.
+func (s *APIKeyService) ListKeys(ctx context.Context, operatorID string) ([]*models.APIKey, error) {
+	return s.keys.ListKeys(ctx, operatorID)
+}
+
+// RevokeKey revokes an API key of an operator so it can no longer authenticate.
+// An empty operatorID allows revoking keys of any operator.
+func (s *APIKeyService) RevokeKey(ctx context.Context, operatorID string, id int) error {
+	err := s.keys.RevokeKey(ctx, operatorID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no active API key with id %d: %w", id, err)
+	}
+	return err
+}
+
+// Authenticate resolves an API key to its principal. The bootstrap admin key
+// from the configuration is accepted in addition to keys stored in the database.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*auth.Principal, error) {
+	if s.cfg.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(plaintext), []byte(s.cfg.AdminAPIKey)) == 1 {
+		return &auth.Principal{
+			Subject:    "bootstrap-admin",
+			Role:       auth.RoleAdmin,
+			OperatorID: s.cfg.SuperTenantID,
+		}, nil
+	}
+
+	key, err := s.keys.FindKey(ctx, hashAPIKey(plaintext))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Keys from before tenancy have no operator and no access until one is assigned
+	if key.OperatorID == "" {
+		logging.FromContext(ctx).Warn("Rejected API key without an operator", "key_id", key.ID, "key_prefix", key.Prefix)
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &auth.Principal{
+		Subject:    key.Name,
+		Role:       auth.Role(key.Role),
+		OperatorID: key.OperatorID,
+		KeyID:      key.ID,
+	}, nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"transjakarta-fleet/internal/auth"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository/memory"
+)
+
+func TestAPIKeyServiceAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	cfg.AdminAPIKey = "bootstrap-secret"
+	store := memory.New()
+	service := NewAPIKeyService(store, cfg)
+
+	dispatcher, err := service.CreateKey(ctx, "dashboard", auth.RoleDispatcher, "op1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := service.CreateKey(ctx, "old-dashboard", auth.RoleViewer, "op1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RevokeKey(ctx, "op1", revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Stored before tenancy, so without an operator
+	if err := store.CreateKey(ctx, &models.APIKey{Name: "legacy", Role: string(auth.RoleViewer), Prefix: "tjf_lega"}, hashAPIKey("tjf_legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		wantErr      error
+		wantRole     auth.Role
+		wantOperator string
+	}{
+		{name: "stored key", key: dispatcher.Key, wantRole: auth.RoleDispatcher, wantOperator: "op1"},
+		{name: "bootstrap admin key", key: "bootstrap-secret", wantRole: auth.RoleAdmin, wantOperator: cfg.SuperTenantID},
+		{name: "unknown key", key: "tjf_unknown", wantErr: ErrInvalidAPIKey},
+		{name: "revoked key", key: revoked.Key, wantErr: ErrInvalidAPIKey},
+		{name: "key without an operator", key: "tjf_legacy", wantErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := service.Authenticate(ctx, tt.key)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate() = %+v, %v, want %v", principal, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Role != tt.wantRole || principal.OperatorID != tt.wantOperator {
+				t.Errorf("principal = %+v, want role %s of operator %s", principal, tt.wantRole, tt.wantOperator)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+)
+
+const (
+	// nullIslandTolerance is how close in degrees, about 11 m, a point may be to
+	// 0,0 before it is taken for a receiver reporting without a fix
+	nullIslandTolerance = 1e-4
+
+	// minJumpDistance is the shortest move in meters checked against the maximum
+	// plausible speed, since GPS error alone can shift a fix this far between pings
+	minJumpDistance = 100
+)
+
+// PlausibilityFilter catches points a vehicle cannot have been at: fixes at
+// 0,0, points outside the service area and jumps from the previous point that
+// imply an impossible speed
+type PlausibilityFilter struct {
+	cfg *config.Config
+}
+
+func NewPlausibilityFilter(cfg *config.Config) *PlausibilityFilter {
+	return &PlausibilityFilter{cfg: cfg}
+}
+
+// Check returns an *ingest.Rejection if location is implausible. previous is the
+// vehicle's newest accepted location, or nil; only locations after it are
+// checked for jumps.
+func (f *PlausibilityFilter) Check(location, previous *models.VehicleLocation) error {
+	if math.Abs(location.Latitude) < nullIslandTolerance && math.Abs(location.Longitude) < nullIslandTolerance {
+		return &ingest.Rejection{
+			Reason:  ingest.ReasonNullIsland,
+			Message: "location is at 0,0, the device likely has no GPS fix",
+		}
+	}
+
+	if f.cfg.HasServiceArea() && !f.insideServiceArea(location.Latitude, location.Longitude) {
+		return &ingest.Rejection{
+			Reason:  ingest.ReasonOutsideServiceArea,
+			Message: fmt.Sprintf("location %g,%g is outside the service area", location.Latitude, location.Longitude),
+		}
+	}
+
+	if previous == nil || location.Timestamp <= previous.Timestamp {
+		return nil
+	}
+
+	distance := haversineDistance(previous.Latitude, previous.Longitude, location.Latitude, location.Longitude)
+	if distance < minJumpDistance {
+		return nil
+	}
+
+	dt := location.Timestamp - previous.Timestamp
+	speed := distance / float64(dt) * 3.6 // km/h
+	if speed > f.cfg.MaxPlausibleSpeed {
+		return &ingest.Rejection{
+			Reason: ingest.ReasonImpossibleJump,
+			Message: fmt.Sprintf("moved %.0f m in %ds since the previous location, implying %.0f km/h, more than the plausible %g km/h",
+				distance, dt, speed, f.cfg.MaxPlausibleSpeed),
+		}
+	}
+
+	return nil
+}
+
+func (f *PlausibilityFilter) insideServiceArea(lat, lon float64) bool {
+	return lat >= f.cfg.ServiceAreaMinLatitude && lat <= f.cfg.ServiceAreaMaxLatitude &&
+		lon >= f.cfg.ServiceAreaMinLongitude && lon <= f.cfg.ServiceAreaMaxLongitude
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+)
+
+func TestPlausibilityFilterCheck(t *testing.T) {
+	at := func(lat, lon float64, timestamp int64) *models.VehicleLocation {
+		return &models.VehicleLocation{VehicleID: "B1", Latitude: lat, Longitude: lon, Timestamp: timestamp}
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(cfg *config.Config)
+		location   *models.VehicleLocation
+		previous   *models.VehicleLocation
+		wantReason string
+	}{
+		{
+			name:     "first location in the service area",
+			modify:   jabodetabek,
+			location: at(-6.2, 106.8, 1000),
+		},
+		{
+			name:       "null island",
+			modify:     jabodetabek,
+			location:   at(0.00001, -0.00002, 1000),
+			wantReason: ingest.ReasonNullIsland,
+		},
+		{
+			name:       "null island without a service area",
+			location:   at(0, 0, 1000),
+			wantReason: ingest.ReasonNullIsland,
+		},
+		{
+			name:       "outside the service area",
+			modify:     jabodetabek,
+			location:   at(-7.5, 110.4, 1000),
+			wantReason: ingest.ReasonOutsideServiceArea,
+		},
+		{
+			name:     "anywhere without a service area",
+			location: at(-7.5, 110.4, 1000),
+		},
+		{
+			// 0.01 degrees of latitude, about 1.1 km, in a minute
+			name:     "plausible speed",
+			location: at(-6.19, 106.8, 1060),
+			previous: at(-6.2, 106.8, 1000),
+		},
+		{
+			name:       "impossible jump",
+			location:   at(-6.1, 106.8, 1060),
+			previous:   at(-6.2, 106.8, 1000),
+			wantReason: ingest.ReasonImpossibleJump,
+		},
+		{
+			name: "jump within a raised speed limit",
+			modify: func(cfg *config.Config) {
+				cfg.MaxPlausibleSpeed = 1000
+			},
+			location: at(-6.1, 106.8, 1060),
+			previous: at(-6.2, 106.8, 1000),
+		},
+		{
+			// GPS error alone can move a fix this far between two pings
+			name:     "short move in a second",
+			location: at(-6.2005, 106.8, 1001),
+			previous: at(-6.2, 106.8, 1000),
+		},
+		{
+			name:     "late location far from the newest",
+			location: at(-6.1, 106.8, 900),
+			previous: at(-6.2, 106.8, 1000),
+		},
+		{
+			name:     "same timestamp far from the newest",
+			location: at(-6.1, 106.8, 1000),
+			previous: at(-6.2, 106.8, 1000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+
+			err := NewPlausibilityFilter(cfg).Check(tt.location, tt.previous)
+			checkRejection(t, err, tt.wantReason)
+		})
+	}
+}
+
+// jabodetabek sets the service area of config.example.yaml
+func jabodetabek(cfg *config.Config) {
+	cfg.ServiceAreaMinLatitude = -6.9
+	cfg.ServiceAreaMaxLatitude = -5.9
+	cfg.ServiceAreaMinLongitude = 106.3
+	cfg.ServiceAreaMaxLongitude = 107.3
+}
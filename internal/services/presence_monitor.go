@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/models"
+)
+
+const (
+	EventVehicleOnline  = "vehicle_online"
+	EventVehicleOffline = "vehicle_offline"
+)
+
+// PresenceMonitor tracks when each vehicle last reported and raises online/offline events
+type PresenceMonitor struct {
+	publisher events.Publisher
+	cfg       *config.Config
+	mu        sync.Mutex
+	vehicles  map[string]*presence
+
+	lastTick atomic.Int64 // unix nanoseconds of the last check, zero until Run starts
+}
+
+type presence struct {
+	location *models.VehicleLocation
+	lastSeen time.Time
+	offline  bool
+}
+
+func NewPresenceMonitor(publisher events.Publisher, cfg *config.Config) *PresenceMonitor {
+	return &PresenceMonitor{
+		publisher: publisher,
+		cfg:       cfg,
+		vehicles:  make(map[string]*presence),
+	}
+}
+
+// Seed loads the last known locations so vehicles that stopped reporting before
+// a restart are still tracked. Vehicles already past the offline threshold are
+// marked offline without publishing an event.
+func (m *PresenceMonitor) Seed(locations []*models.LocationStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, location := range locations {
+		lastSeen := time.Unix(location.Timestamp, 0)
+		m.vehicles[location.VehicleID] = &presence{
+			location: &location.VehicleLocation,
+			lastSeen: lastSeen,
+			offline:  now.Sub(lastSeen) > m.cfg.OfflineThreshold,
+		}
+	}
+}
+
+// Seen records that a vehicle reported a location, publishing vehicle_online if it was offline
+func (m *PresenceMonitor) Seen(ctx context.Context, location *models.VehicleLocation) {
+	now := time.Now()
+
+	m.mu.Lock()
+	p, ok := m.vehicles[location.VehicleID]
+	if !ok {
+		p = &presence{}
+		m.vehicles[location.VehicleID] = p
+	}
+	wasOffline := p.offline
+	p.location = location
+	p.lastSeen = now
+	p.offline = false
+	m.mu.Unlock()
+
+	if wasOffline {
+		m.publish(ctx, newPresenceEvent(EventVehicleOnline, location, now, now))
+	}
+}
+
+// Run periodically checks for vehicles that stopped reporting until ctx is cancelled
+func (m *PresenceMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.PresenceCheckInterval)
+	defer ticker.Stop()
+
+	slog.Info("Presence monitor started", "offline_threshold", m.cfg.OfflineThreshold.String())
+	m.lastTick.Store(time.Now().UnixNano())
+	defer m.lastTick.Store(0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.lastTick.Store(now.UnixNano())
+			for _, event := range m.check(now) {
+				m.publish(ctx, event)
+			}
+		}
+	}
+}
+
+func (m *PresenceMonitor) check(now time.Time) []*models.PresenceEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []*models.PresenceEvent
+	for _, p := range m.vehicles {
+		if p.offline || now.Sub(p.lastSeen) <= m.cfg.OfflineThreshold {
+			continue
+		}
+		p.offline = true
+		events = append(events, newPresenceEvent(EventVehicleOffline, p.location, p.lastSeen, now))
+	}
+	return events
+}
+
+// Check reports an error if Run is not running or has missed several checks in a row
+func (m *PresenceMonitor) Check(ctx context.Context) error {
+	lastTick := m.lastTick.Load()
+	if lastTick == 0 {
+		return errors.New("presence monitor not running")
+	}
+	if since := time.Since(time.Unix(0, lastTick)); since > 3*m.cfg.PresenceCheckInterval {
+		return fmt.Errorf("presence monitor last ran %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// Status annotates a location with its age and whether it is stale. Every location read served to clients goes through it.
+func (m *PresenceMonitor) Status(location *models.VehicleLocation) *models.LocationStatus {
+	age := time.Since(time.Unix(location.Timestamp, 0))
+	return &models.LocationStatus{
+		VehicleLocation: *location,
+		Stale:           age > m.cfg.StaleThreshold,
+		AgeSeconds:      int64(age / time.Second),
+	}
+}
+
+func (m *PresenceMonitor) publish(ctx context.Context, event *models.PresenceEvent) {
+	if err := m.publisher.PublishPresenceEvent(ctx, event); err != nil {
+		logging.FromContext(ctx).Error("Failed to publish presence event", "vehicle_id", event.VehicleID, "event", event.Event, "error", err)
+	}
+}
+
+func newPresenceEvent(event string, location *models.VehicleLocation, lastSeen, now time.Time) *models.PresenceEvent {
+	return &models.PresenceEvent{
+		VehicleID: location.VehicleID,
+		Event:     event,
+		Location: models.Location{
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+		},
+		LastSeen:  lastSeen.Unix(),
+		Timestamp: now.Unix(),
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/models"
+)
+
+func TestPresenceMonitorStatus(t *testing.T) {
+	cfg := newTestConfig(t)
+	monitor := NewPresenceMonitor(events.NewMemory(), cfg)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		age       time.Duration
+		wantStale bool
+	}{
+		{name: "fresh", age: 5 * time.Second},
+		{name: "just under the threshold", age: cfg.StaleThreshold - time.Second},
+		{name: "over the threshold", age: cfg.StaleThreshold + time.Second, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := &models.VehicleLocation{VehicleID: "B1", Timestamp: now.Add(-tt.age).Unix()}
+			status := monitor.Status(location)
+			if status.Stale != tt.wantStale {
+				t.Errorf("Stale = %v, want %v", status.Stale, tt.wantStale)
+			}
+			// Status reads the clock itself, so allow for a second passing
+			if want := int64(tt.age / time.Second); status.AgeSeconds < want || status.AgeSeconds > want+1 {
+				t.Errorf("AgeSeconds = %d, want %d", status.AgeSeconds, want)
+			}
+		})
+	}
+}
+
+func TestPresenceMonitorOffline(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	bus := events.NewMemory()
+	monitor := NewPresenceMonitor(bus, cfg)
+	now := time.Now()
+
+	// B1 was already offline before the restart, B2 reported just before it
+	monitor.Seed([]*models.LocationStatus{
+		{VehicleLocation: models.VehicleLocation{VehicleID: "B1", Timestamp: now.Add(-2 * cfg.OfflineThreshold).Unix()}},
+		{VehicleLocation: models.VehicleLocation{VehicleID: "B2", Timestamp: now.Add(-time.Second).Unix()}},
+	})
+
+	presence := func() []string {
+		var got []string
+		for _, event := range bus.PresenceEvents() {
+			got = append(got, event.VehicleID+" "+event.Event)
+		}
+		return got
+	}
+
+	for _, event := range monitor.check(now.Add(cfg.OfflineThreshold)) {
+		monitor.publish(ctx, event)
+	}
+	if got := presence(); len(got) != 1 || got[0] != "B2 "+EventVehicleOffline {
+		t.Fatalf("events after the threshold = %v, want B2 offline only", got)
+	}
+
+	monitor.Seen(ctx, &models.VehicleLocation{VehicleID: "B1", Timestamp: now.Unix()})
+	if got := presence(); len(got) != 2 || got[1] != "B1 "+EventVehicleOnline {
+		t.Errorf("events after B1 reported = %v, want B1 online", got)
+	}
+}
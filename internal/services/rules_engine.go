@@ -0,0 +1,133 @@
+package services
+
+import (
+	"sync"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/models"
+)
+
+const (
+	EventSpeeding          = "speeding"
+	EventHarshBraking      = "harsh_braking"
+	EventHarshAcceleration = "harsh_acceleration"
+
+	// maxRuleGap is the longest gap in seconds between two points used to derive speed and acceleration
+	maxRuleGap = 60
+)
+
+// RulesEngine derives speed and acceleration from consecutive points and raises driving behaviour events
+type RulesEngine struct {
+	cfg    *config.Config
+	mu     sync.Mutex
+	states map[string]*drivingState
+}
+
+type drivingState struct {
+	location *models.VehicleLocation
+	speed    float64 // m/s
+	hasSpeed bool
+	speeding bool
+}
+
+func NewRulesEngine(cfg *config.Config) *RulesEngine {
+	return &RulesEngine{
+		cfg:    cfg,
+		states: make(map[string]*drivingState),
+	}
+}
+
+// Evaluate updates the vehicle state with a new point and returns the events it triggers.
+// Points older than the last evaluated one are ignored.
+func (e *RulesEngine) Evaluate(location *models.VehicleLocation) []*models.DrivingEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.states[location.VehicleID]
+	if !ok {
+		state = &drivingState{}
+		e.states[location.VehicleID] = state
+	}
+
+	prev := state.location
+	if prev != nil && location.Timestamp <= prev.Timestamp {
+		return nil
+	}
+	state.location = location
+
+	dt := int64(0)
+	if prev != nil {
+		dt = location.Timestamp - prev.Timestamp
+	}
+	contiguous := prev != nil && dt <= maxRuleGap
+
+	var speed float64
+	switch {
+	case location.Speed != nil:
+		speed = *location.Speed / 3.6
+	case contiguous:
+		speed = haversineDistance(prev.Latitude, prev.Longitude, location.Latitude, location.Longitude) / float64(dt)
+	default:
+		state.hasSpeed = false
+		return nil
+	}
+
+	var events []*models.DrivingEvent
+
+	limit, zone := e.speedLimitAt(location.Latitude, location.Longitude)
+	speedKmh := speed * 3.6
+	if speedKmh > limit {
+		if !state.speeding {
+			events = append(events, e.newEvent(location, EventSpeeding, speedKmh, limit, 0, zone))
+		}
+		state.speeding = true
+	} else {
+		state.speeding = false
+	}
+
+	if contiguous && state.hasSpeed {
+		acceleration := (speed - state.speed) / float64(dt)
+		switch {
+		case acceleration <= -e.cfg.HarshBrakingThreshold:
+			events = append(events, e.newEvent(location, EventHarshBraking, speedKmh, 0, acceleration, zone))
+		case acceleration >= e.cfg.HarshAccelerationThreshold:
+			events = append(events, e.newEvent(location, EventHarshAcceleration, speedKmh, 0, acceleration, zone))
+		}
+	}
+
+	state.speed = speed
+	state.hasSpeed = true
+
+	return events
+}
+
+// speedLimitAt returns the speed limit applying at the given point and the zone it comes from.
+// A zone's limit replaces the general one, higher or lower; where zones overlap the lowest applies.
+func (e *RulesEngine) speedLimitAt(lat, lon float64) (float64, string) {
+	limit, zone, inZone := e.cfg.SpeedLimit, "", false
+	for _, z := range e.cfg.Zones().SpeedZones {
+		if inZone && z.SpeedLimit >= limit {
+			continue
+		}
+		if haversineDistance(z.Latitude, z.Longitude, lat, lon) <= z.Radius {
+			limit, zone, inZone = z.SpeedLimit, z.Name, true
+		}
+	}
+	return limit, zone
+}
+
+func (e *RulesEngine) newEvent(location *models.VehicleLocation, event string, speedKmh, limit, acceleration float64, zone string) *models.DrivingEvent {
+	return &models.DrivingEvent{
+		VehicleID: location.VehicleID,
+		Event:     event,
+		Location: models.Location{
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+		},
+		Timestamp:     location.Timestamp,
+		SpeedKmh:      speedKmh,
+		SpeedLimitKmh: limit,
+		Acceleration:  acceleration,
+		Zone:          zone,
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/models"
+)
+
+func TestRulesEngineEvaluate(t *testing.T) {
+	kmh := func(v float64) *float64 { return &v }
+
+	type step struct {
+		location   models.VehicleLocation
+		wantEvents []string
+	}
+	// Away from the geofence, where the general 60 km/h limit applies
+	point := func(vehicleID string, lat float64, timestamp int64, speed *float64) models.VehicleLocation {
+		return models.VehicleLocation{VehicleID: vehicleID, Latitude: lat, Longitude: 106.8, Timestamp: timestamp, Speed: speed}
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "speeding once per episode",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(70)), []string{EventSpeeding}},
+				{point("B1", -6.2, 10, kmh(80)), nil},
+				{point("B1", -6.2, 20, kmh(50)), nil},
+				{point("B1", -6.2, 30, kmh(70)), []string{EventSpeeding}},
+			},
+		},
+		{
+			name: "harsh braking",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(50)), nil},
+				{point("B1", -6.2, 2, kmh(10)), []string{EventHarshBraking}},
+			},
+		},
+		{
+			name: "harsh acceleration",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(0)), nil},
+				{point("B1", -6.2, 2, kmh(40)), []string{EventHarshAcceleration}},
+			},
+		},
+		{
+			name: "gradual speed changes",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(0)), nil},
+				{point("B1", -6.2, 10, kmh(40)), nil},
+				{point("B1", -6.2, 20, kmh(10)), nil},
+			},
+		},
+		{
+			name: "no acceleration across a gap",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(0)), nil},
+				{point("B1", -6.2, 120, kmh(40)), nil},
+			},
+		},
+		{
+			name: "speed derived from consecutive points",
+			steps: []step{
+				{point("B1", -6.2, 0, nil), nil},
+				// 0.01 degrees of latitude in 10s is about 400 km/h
+				{point("B1", -6.19, 10, nil), []string{EventSpeeding}},
+				{point("B1", -6.19, 20, nil), []string{EventHarshBraking}},
+			},
+		},
+		{
+			name: "out of order point ignored",
+			steps: []step{
+				{point("B1", -6.2, 100, kmh(20)), nil},
+				{point("B1", -6.2, 90, kmh(90)), nil},
+				{point("B1", -6.2, 100, kmh(90)), nil},
+			},
+		},
+		{
+			name: "vehicles evaluated separately",
+			steps: []step{
+				{point("B1", -6.2, 0, kmh(50)), nil},
+				{point("B2", -6.2, 2, kmh(10)), nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewRulesEngine(newTestConfig(t))
+			for i, s := range tt.steps {
+				location := s.location
+				var got []string
+				for _, event := range engine.Evaluate(&location) {
+					got = append(got, event.Event)
+				}
+				if !reflect.DeepEqual(got, s.wantEvents) {
+					t.Errorf("step %d: events = %v, want %v", i, got, s.wantEvents)
+				}
+			}
+		})
+	}
+}
+
+func TestRulesEngineSpeedZone(t *testing.T) {
+	cfg := newTestConfig(t)
+	zones := cfg.Zones()
+	// A toll road raising the limit, and a school zone inside it lowering it again
+	cfg.SetZones(&config.Zones{
+		Geofence: zones.Geofence,
+		SpeedZones: append(zones.SpeedZones,
+			config.SpeedZone{Name: "toll-road", Latitude: -6.25, Longitude: 106.85, Radius: 2000, SpeedLimit: 80},
+			config.SpeedZone{Name: "school", Latitude: -6.25, Longitude: 106.85, Radius: 200, SpeedLimit: 20},
+		),
+	})
+
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		speed     float64
+		wantLimit float64 // of the speeding event, none if 0
+		wantZone  string
+	}{
+		// 40 km/h is within the general limit but over the geofence's
+		{name: "lower zone limit", lat: cfg.GeofenceLatitude, lon: cfg.GeofenceLongitude, speed: 40, wantLimit: cfg.GeofenceSpeedLimit, wantZone: "geofence"},
+		{name: "outside every zone", lat: -6.2, lon: 106.8, speed: 70, wantLimit: cfg.SpeedLimit},
+		{name: "within a higher zone limit", lat: -6.26, lon: 106.85, speed: 70},
+		{name: "over a higher zone limit", lat: -6.26, lon: 106.85, speed: 90, wantLimit: 80, wantZone: "toll-road"},
+		{name: "lowest of overlapping zones", lat: -6.25, lon: 106.85, speed: 36, wantLimit: 20, wantZone: "school"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speed := tt.speed
+			events := NewRulesEngine(cfg).Evaluate(&models.VehicleLocation{
+				VehicleID: "B1",
+				Latitude:  tt.lat,
+				Longitude: tt.lon,
+				Timestamp: 1000,
+				Speed:     &speed,
+			})
+
+			if tt.wantLimit == 0 {
+				if len(events) != 0 {
+					t.Fatalf("events = %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			event := events[0]
+			if event.Event != EventSpeeding || event.Zone != tt.wantZone || event.SpeedLimitKmh != tt.wantLimit || event.SpeedKmh != speed {
+				t.Errorf("event = %+v, want speeding in zone %q at %g km/h over %g", event, tt.wantZone, speed, tt.wantLimit)
+			}
+		})
+	}
+}
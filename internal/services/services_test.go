@@ -0,0 +1,18 @@
+package services
+
+import (
+	"testing"
+
+	"transjakarta-fleet/internal/config"
+)
+
+// newTestConfig returns the default configuration
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"transjakarta-fleet/internal/metrics"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository"
+)
+
+const (
+	// movingSpeedThreshold is the speed in m/s above which a segment counts as moving
+	movingSpeedThreshold = 1.0
+	// maxSegmentGap is the longest gap in seconds between two points that is still treated as continuous
+	maxSegmentGap = 300
+	// minStopDuration is the minimum idle time in seconds counted as a stop
+	minStopDuration = 30
+)
+
+// statsLocation is the local time zone used to bucket daily distance
+var statsLocation = time.FixedZone("WIB", 7*60*60)
+
+type StatsService struct {
+	locations repository.LocationRepository
+}
+
+func NewStatsService(locations repository.LocationRepository) *StatsService {
+	return &StatsService{
+		locations: locations,
+	}
+}
+
+// GetVehicleStats computes trip and distance statistics for a vehicle within a time range.
+// An empty operatorID includes locations of all operators.
+func (s *StatsService) GetVehicleStats(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64) (_ *models.VehicleStats, err error) {
+	defer metrics.ObserveQuery("GetVehicleStats", time.Now(), &err)
+
+	acc := newStatsAccumulator(vehicleID, startTime, endTime)
+	err = s.locations.StreamHistory(ctx, operatorID, vehicleID, startTime, endTime, func(location *models.VehicleLocation) error {
+		acc.add(location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.result(), nil
+}
+
+// GetFleetStats computes statistics for every vehicle of an operator that reported within a time range.
+// An empty operatorID covers the whole fleet.
+func (s *StatsService) GetFleetStats(ctx context.Context, operatorID string, startTime, endTime int64) (_ *models.FleetStats, err error) {
+	defer metrics.ObserveQuery("GetFleetStats", time.Now(), &err)
+
+	fleet := &models.FleetStats{
+		StartTime:  startTime,
+		EndTime:    endTime,
+		PerVehicle: []*models.VehicleStats{},
+	}
+
+	var acc *statsAccumulator
+	err = s.locations.StreamFleetHistory(ctx, operatorID, startTime, endTime, func(location *models.VehicleLocation) error {
+		if acc == nil || acc.vehicleID != location.VehicleID {
+			if acc != nil {
+				fleet.PerVehicle = append(fleet.PerVehicle, acc.result())
+			}
+			acc = newStatsAccumulator(location.VehicleID, startTime, endTime)
+		}
+		acc.add(location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if acc != nil {
+		fleet.PerVehicle = append(fleet.PerVehicle, acc.result())
+	}
+
+	for _, stats := range fleet.PerVehicle {
+		fleet.Vehicles++
+		fleet.DistanceKm += stats.DistanceKm
+		fleet.MovingSeconds += stats.MovingSeconds
+		fleet.IdleSeconds += stats.IdleSeconds
+		fleet.Stops += stats.Stops
+		fleet.MaxSpeedKmh = math.Max(fleet.MaxSpeedKmh, stats.MaxSpeedKmh)
+	}
+	if fleet.MovingSeconds > 0 {
+		fleet.AvgSpeedKmh = fleet.DistanceKm / (float64(fleet.MovingSeconds) / 3600)
+	}
+
+	return fleet, nil
+}
+
+// statsAccumulator folds an ordered stream of locations into VehicleStats
+type statsAccumulator struct {
+	vehicleID   string
+	stats       *models.VehicleStats
+	daily       map[string]float64
+	days        []string
+	distance    float64
+	maxSpeed    float64
+	prev        *models.VehicleLocation
+	idleSeconds int64
+}
+
+func newStatsAccumulator(vehicleID string, startTime, endTime int64) *statsAccumulator {
+	return &statsAccumulator{
+		vehicleID: vehicleID,
+		stats: &models.VehicleStats{
+			VehicleID: vehicleID,
+			StartTime: startTime,
+			EndTime:   endTime,
+			Daily:     []models.DailyDistance{},
+		},
+		daily: make(map[string]float64),
+	}
+}
+
+func (a *statsAccumulator) add(location *models.VehicleLocation) {
+	// A point the plausibility filter flagged would add its jump to the distance
+	if location.Implausible != "" {
+		return
+	}
+
+	a.stats.Points++
+	prev := a.prev
+	a.prev = location
+	if prev == nil {
+		return
+	}
+
+	dt := location.Timestamp - prev.Timestamp
+	if dt <= 0 || dt > maxSegmentGap {
+		a.endIdle()
+		return
+	}
+
+	distance := haversineDistance(prev.Latitude, prev.Longitude, location.Latitude, location.Longitude)
+	speed := distance / float64(dt)
+
+	if speed < movingSpeedThreshold {
+		a.stats.IdleSeconds += dt
+		a.idleSeconds += dt
+		return
+	}
+
+	a.endIdle()
+	a.stats.MovingSeconds += dt
+	a.distance += distance
+	a.maxSpeed = math.Max(a.maxSpeed, speed)
+
+	day := time.Unix(location.Timestamp, 0).In(statsLocation).Format("2006-01-02")
+	if _, ok := a.daily[day]; !ok {
+		a.days = append(a.days, day)
+	}
+	a.daily[day] += distance
+}
+
+// endIdle closes the current idle run and counts it as a stop if it lasted long enough
+func (a *statsAccumulator) endIdle() {
+	if a.idleSeconds >= minStopDuration {
+		a.stats.Stops++
+	}
+	a.idleSeconds = 0
+}
+
+func (a *statsAccumulator) result() *models.VehicleStats {
+	a.endIdle()
+
+	a.stats.DistanceKm = a.distance / 1000
+	a.stats.MaxSpeedKmh = a.maxSpeed * 3.6
+	if a.stats.MovingSeconds > 0 {
+		a.stats.AvgSpeedKmh = a.distance / float64(a.stats.MovingSeconds) * 3.6
+	}
+	for _, day := range a.days {
+		a.stats.Daily = append(a.stats.Daily, models.DailyDistance{
+			Date:       day,
+			DistanceKm: a.daily[day] / 1000,
+		})
+	}
+
+	return a.stats
+}
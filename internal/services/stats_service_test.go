@@ -0,0 +1,136 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"transjakarta-fleet/internal/models"
+)
+
+func TestStatsAccumulator(t *testing.T) {
+	// 0.001 degrees of latitude, about 111 m: moving at 40 km/h over 10s
+	const step = 0.001
+	stepMeters := haversineDistance(-6.2, 106.8, -6.2+step, 106.8)
+
+	// 23:59:40 WIB on 2023-11-15
+	const beforeMidnight = 1700067580
+
+	type point struct {
+		lat         float64
+		timestamp   int64
+		implausible string
+	}
+	tests := []struct {
+		name          string
+		points        []point
+		wantPoints    int
+		wantMoving    int64
+		wantIdle      int64
+		wantStops     int
+		wantDistance  float64 // meters
+		wantMaxSpeed  float64 // km/h
+		wantDailyDays []string
+	}{
+		{
+			name: "no locations",
+		},
+		{
+			name:         "moving",
+			points:       []point{{-6.2, 0, ""}, {-6.2 + step, 10, ""}, {-6.2 + 2*step, 20, ""}},
+			wantPoints:   3,
+			wantMoving:   20,
+			wantDistance: 2 * stepMeters,
+			wantMaxSpeed: stepMeters / 10 * 3.6,
+		},
+		{
+			name:         "idle long enough for a stop",
+			points:       []point{{-6.2, 0, ""}, {-6.2, 20, ""}, {-6.2, 40, ""}, {-6.2 + step, 50, ""}},
+			wantPoints:   4,
+			wantMoving:   10,
+			wantIdle:     40,
+			wantStops:    1,
+			wantDistance: stepMeters,
+			wantMaxSpeed: stepMeters / 10 * 3.6,
+		},
+		{
+			name:       "short idle is no stop",
+			points:     []point{{-6.2, 0, ""}, {-6.2, 20, ""}},
+			wantPoints: 2,
+			wantIdle:   20,
+		},
+		{
+			name:       "idle at the end counts as a stop",
+			points:     []point{{-6.2, 0, ""}, {-6.2, 30, ""}, {-6.2, 60, ""}},
+			wantPoints: 3,
+			wantIdle:   60,
+			wantStops:  1,
+		},
+		{
+			name:       "gap breaks the segment",
+			points:     []point{{-6.2, 0, ""}, {-6.2 + step, 1000, ""}},
+			wantPoints: 2,
+		},
+		{
+			// The flagged point would add two 11 km jumps
+			name:         "implausible location skipped",
+			points:       []point{{-6.2, 0, ""}, {-6.1, 5, "impossible_jump"}, {-6.2 + step, 10, ""}},
+			wantPoints:   2,
+			wantMoving:   10,
+			wantDistance: stepMeters,
+			wantMaxSpeed: stepMeters / 10 * 3.6,
+		},
+		{
+			name: "distance split by local day",
+			points: []point{
+				{-6.2, beforeMidnight, ""},
+				{-6.2 + step, beforeMidnight + 10, ""},
+				{-6.2 + 2*step, beforeMidnight + 20, ""},
+			},
+			wantPoints:    3,
+			wantMoving:    20,
+			wantDistance:  2 * stepMeters,
+			wantMaxSpeed:  stepMeters / 10 * 3.6,
+			wantDailyDays: []string{"2023-11-15", "2023-11-16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := newStatsAccumulator("B1", 0, math.MaxInt64)
+			for _, p := range tt.points {
+				acc.add(&models.VehicleLocation{VehicleID: "B1", Latitude: p.lat, Longitude: 106.8, Timestamp: p.timestamp, Implausible: p.implausible})
+			}
+			got := acc.result()
+
+			if got.Points != tt.wantPoints || got.MovingSeconds != tt.wantMoving || got.IdleSeconds != tt.wantIdle || got.Stops != tt.wantStops {
+				t.Errorf("points %d, moving %ds, idle %ds, stops %d, want %d, %ds, %ds, %d",
+					got.Points, got.MovingSeconds, got.IdleSeconds, got.Stops,
+					tt.wantPoints, tt.wantMoving, tt.wantIdle, tt.wantStops)
+			}
+			if !approxEqual(got.DistanceKm, tt.wantDistance/1000) {
+				t.Errorf("distance = %g km, want %g", got.DistanceKm, tt.wantDistance/1000)
+			}
+			if !approxEqual(got.MaxSpeedKmh, tt.wantMaxSpeed) {
+				t.Errorf("max speed = %g km/h, want %g", got.MaxSpeedKmh, tt.wantMaxSpeed)
+			}
+			if tt.wantMoving > 0 && !approxEqual(got.AvgSpeedKmh, tt.wantDistance/float64(tt.wantMoving)*3.6) {
+				t.Errorf("average speed = %g km/h, want %g", got.AvgSpeedKmh, tt.wantDistance/float64(tt.wantMoving)*3.6)
+			}
+
+			if tt.wantDailyDays != nil {
+				if len(got.Daily) != len(tt.wantDailyDays) {
+					t.Fatalf("daily = %+v, want days %v", got.Daily, tt.wantDailyDays)
+				}
+				for i, day := range tt.wantDailyDays {
+					if got.Daily[i].Date != day || !approxEqual(got.Daily[i].DistanceKm, stepMeters/1000) {
+						t.Errorf("daily[%d] = %+v, want %s with %g km", i, got.Daily[i], day, stepMeters/1000)
+					}
+				}
+			}
+		})
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
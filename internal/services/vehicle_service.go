@@ -1,44 +1,151 @@
 package services
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
 	"transjakarta-fleet/internal/models"
-	"transjakarta-fleet/internal/rabbitmq"
+	"transjakarta-fleet/internal/repository"
+	"transjakarta-fleet/internal/tracing"
 )
 
 type VehicleService struct {
-	db     *sql.DB
-	rabbit *rabbitmq.RabbitMQ
-	cfg    *config.Config
+	locations repository.LocationRepository
+	vehicles  repository.VehicleRepository
+	publisher events.Publisher
+	cfg       *config.Config
+	rules     *RulesEngine
+	filter    *PlausibilityFilter
+	presence  *PresenceMonitor
+
+	mu     sync.Mutex                // guards newest
+	newest map[string]newestLocation // newest accepted location by vehicle
+
+	vehicleLocks [vehicleLockStripes]sync.Mutex // serialize saves of a vehicle, see vehicleLock
+}
+
+// vehicleLockStripes is how many locks the vehicles share. Vehicles hashing to
+// the same stripe wait for each other, which only costs throughput.
+const vehicleLockStripes = 64
+
+// newestLocation is a vehicle's newest accepted location, or the zero value if
+// it has none
+type newestLocation struct {
+	location models.VehicleLocation
+	loaded   bool
 }
 
-func NewVehicleService(db *sql.DB, rabbit *rabbitmq.RabbitMQ, cfg *config.Config) *VehicleService {
+func NewVehicleService(locations repository.LocationRepository, vehicles repository.VehicleRepository, publisher events.Publisher, cfg *config.Config, presence *PresenceMonitor) *VehicleService {
 	return &VehicleService{
-		db:     db,
-		rabbit: rabbit,
-		cfg:    cfg,
+		locations: locations,
+		vehicles:  vehicles,
+		publisher: publisher,
+		cfg:       cfg,
+		rules:     NewRulesEngine(cfg),
+		filter:    NewPlausibilityFilter(cfg),
+		presence:  presence,
+		newest:    make(map[string]newestLocation),
 	}
 }
 
-// SaveLocation saves vehicle location to database and checks geofence
-func (s *VehicleService) SaveLocation(location *models.VehicleLocation) error {
-	query := `
-		INSERT INTO vehicle_locations (vehicle_id, latitude, longitude, timestamp)
-		VALUES ($1, $2, $3, $4)
-	`
+// SaveLocation saves vehicle location to database and checks geofence.
+// The location is attributed to the operator the vehicle is registered to,
+// or to the super-tenant if the vehicle is not registered.
+//
+// A location no newer than one the vehicle already reported is saved as late,
+// for the history, but skips presence, geofence and driving rule checks, which
+// describe where the vehicle is now. A location dated further in the future
+// than MaxClockSkew is rejected with an *ingest.Rejection, as is one the
+// plausibility filter catches unless PlausibilityMode is flag, in which case it
+// is saved marked implausible and skips the same checks as a late one.
+func (s *VehicleService) SaveLocation(ctx context.Context, location *models.VehicleLocation) (err error) {
+	if err := ingest.CheckTimestamp(location, time.Now(), s.cfg.MaxClockSkew); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		// A rejected location is bad input, not a failed query
+		var rejection *ingest.Rejection
+		if !errors.As(err, &rejection) {
+			metrics.ObserveQuery("SaveLocation", start, &err)
+		}
+	}()
+
+	ctx, span := tracing.Start(ctx, "VehicleService.SaveLocation",
+		trace.WithAttributes(attribute.String("vehicle.id", location.VehicleID)),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	// The same vehicle can report over HTTP and MQTT at once. Without the lock
+	// both saves could compare against the same newest location, neither would
+	// be late, and geofence and rules would see them out of order.
+	lock := s.vehicleLock(location.VehicleID)
+	lock.Lock()
+	defer lock.Unlock()
 
-	_, err := s.db.Exec(query, location.VehicleID, location.Latitude, location.Longitude, location.Timestamp)
+	previous, err := s.newestLocation(ctx, location.VehicleID)
 	if err != nil {
-		return fmt.Errorf("failed to save location: %w", err)
+		return err
+	}
+	location.Late = previous != nil && location.Timestamp <= previous.Timestamp
+
+	location.Implausible = ""
+	if s.cfg.PlausibilityMode != config.PlausibilityOff {
+		var rejection *ingest.Rejection
+		if errors.As(s.filter.Check(location, previous), &rejection) {
+			metrics.ImplausibleLocations.WithLabelValues(rejection.Reason, s.cfg.PlausibilityMode).Inc()
+			span.SetAttributes(attribute.String("location.implausible", rejection.Reason))
+			if s.cfg.PlausibilityMode == config.PlausibilityReject {
+				return rejection
+			}
+			location.Implausible = rejection.Reason
+		}
+	}
+
+	if err := s.locations.SaveLocation(ctx, location, s.cfg.SuperTenantID); err != nil {
+		return err
+	}
+
+	if location.Implausible != "" {
+		logging.FromContext(ctx).Warn("Saved implausible location", "vehicle_id", location.VehicleID,
+			"reason", location.Implausible, "latitude", location.Latitude, "longitude", location.Longitude)
+		return nil
+	}
+	if location.Late {
+		metrics.LateLocations.Inc()
+		span.SetAttributes(attribute.Bool("location.late", true))
+		logging.FromContext(ctx).Debug("Saved late location", "vehicle_id", location.VehicleID,
+			"timestamp", location.Timestamp, "newest_timestamp", previous.Timestamp)
+		return nil
 	}
+	s.advance(location)
+
+	s.presence.Seen(ctx, location)
 
 	// Check geofence
 	if s.isInsideGeofence(location.Latitude, location.Longitude) {
+		metrics.GeofenceHits.Inc()
+		span.SetAttributes(attribute.Bool("geofence.inside", true))
+
 		event := &models.GeofenceEvent{
 			VehicleID: location.VehicleID,
 			Event:     "geofence_entry",
@@ -49,92 +156,180 @@ func (s *VehicleService) SaveLocation(location *models.VehicleLocation) error {
 			Timestamp: location.Timestamp,
 		}
 
-		if err := s.rabbit.PublishGeofenceEvent(event); err != nil {
-			log.Printf("Failed to publish geofence event: %v", err)
+		if err := s.publisher.PublishGeofenceEvent(ctx, event); err != nil {
+			logging.FromContext(ctx).Error("Failed to publish geofence event", "vehicle_id", event.VehicleID, "error", err)
+		}
+	}
+
+	// Evaluate driving behaviour rules
+	for _, event := range s.rules.Evaluate(location) {
+		if err := s.publisher.PublishDrivingEvent(ctx, event); err != nil {
+			logging.FromContext(ctx).Error("Failed to publish driving event", "vehicle_id", event.VehicleID, "event", event.Event, "error", err)
 		}
 	}
 
 	return nil
 }
 
-// GetLastLocation retrieves the last known location of a vehicle
-func (s *VehicleService) GetLastLocation(vehicleID string) (*models.VehicleLocation, error) {
-	query := `
-		SELECT vehicle_id, latitude, longitude, timestamp
-		FROM vehicle_locations
-		WHERE vehicle_id = $1
-		ORDER BY timestamp DESC
-		LIMIT 1
-	`
-
-	location := &models.VehicleLocation{}
-	err := s.db.QueryRow(query, vehicleID).Scan(
-		&location.VehicleID,
-		&location.Latitude,
-		&location.Longitude,
-		&location.Timestamp,
-	)
+// newestLocation returns the newest location accepted for the vehicle, or nil
+// if it has none. The first call for a vehicle reads it from storage. The
+// caller holds the vehicle's lock.
+func (s *VehicleService) newestLocation(ctx context.Context, vehicleID string) (*models.VehicleLocation, error) {
+	s.mu.Lock()
+	newest, ok := s.newest[vehicleID]
+	s.mu.Unlock()
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no location found for vehicle %s", vehicleID)
+	if !ok {
+		location, err := s.locations.LastLocation(ctx, "", vehicleID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("failed to load newest location: %w", err)
+		}
+
+		newest = newestLocation{loaded: location != nil}
+		if location != nil {
+			newest.location = *location
+		}
+		s.mu.Lock()
+		s.newest[vehicleID] = newest
+		s.mu.Unlock()
+	}
+
+	if !newest.loaded {
+		return nil, nil
 	}
+	location := newest.location
+	return &location, nil
+}
+
+// advance records location as the vehicle's newest. The caller holds the vehicle's lock.
+func (s *VehicleService) advance(location *models.VehicleLocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.newest[location.VehicleID] = newestLocation{location: *location, loaded: true}
+}
+
+// vehicleLock returns the lock serializing saves of a vehicle
+func (s *VehicleService) vehicleLock(vehicleID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(vehicleID))
+	return &s.vehicleLocks[h.Sum32()%vehicleLockStripes]
+}
+
+// SaveLocations saves a batch of locations, such as the points a unit buffered
+// while out of coverage, one by one in timestamp order, keeping the batch order
+// among equal timestamps. Geofence and driving rules thus see the trip as it
+// happened rather than in the order the points were sent. Each location it gets
+// to is passed to done with the result of SaveLocation: nil once saved, or an
+// *ingest.Rejection, after which the batch goes on. It stops at the first other
+// error, leaving the remaining locations unsaved, and returns how many were saved.
+func (s *VehicleService) SaveLocations(ctx context.Context, locations []*models.VehicleLocation, done func(*models.VehicleLocation, error)) (int, error) {
+	ordered := slices.Clone(locations)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Timestamp < ordered[j].Timestamp })
+
+	saved := 0
+	for _, location := range ordered {
+		err := s.SaveLocation(ctx, location)
+		done(location, err)
 
+		var rejection *ingest.Rejection
+		if errors.As(err, &rejection) {
+			continue
+		}
+		if err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// GetLastLocation retrieves the last known location of a vehicle with its age and stale flag.
+// An empty operatorID searches across all operators.
+func (s *VehicleService) GetLastLocation(ctx context.Context, operatorID, vehicleID string) (_ *models.LocationStatus, err error) {
+	defer metrics.ObserveQuery("GetLastLocation", time.Now(), &err)
+
+	location, err := s.locations.LastLocation(ctx, operatorID, vehicleID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("no location found for vehicle %s", vehicleID)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get location: %w", err)
+		return nil, err
 	}
 
-	return location, nil
+	return s.presence.Status(location), nil
 }
 
-// GetLocationHistory retrieves location history for a vehicle within a time range
-func (s *VehicleService) GetLocationHistory(vehicleID string, startTime, endTime int64) ([]*models.VehicleLocation, error) {
-	query := `
-		SELECT vehicle_id, latitude, longitude, timestamp
-		FROM vehicle_locations
-		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp <= $3
-		ORDER BY timestamp ASC
-	`
+// GetLatestLocations retrieves the most recent location of every vehicle of an operator with its age and stale flag.
+// An empty operatorID returns vehicles of all operators.
+func (s *VehicleService) GetLatestLocations(ctx context.Context, operatorID string) (_ []*models.LocationStatus, err error) {
+	defer metrics.ObserveQuery("GetLatestLocations", time.Now(), &err)
 
-	rows, err := s.db.Query(query, vehicleID, startTime, endTime)
+	locations, err := s.locations.LatestLocations(ctx, operatorID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query location history: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	var locations []*models.VehicleLocation
-	for rows.Next() {
-		location := &models.VehicleLocation{}
-		if err := rows.Scan(
-			&location.VehicleID,
-			&location.Latitude,
-			&location.Longitude,
-			&location.Timestamp,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-		locations = append(locations, location)
+	statuses := make([]*models.LocationStatus, len(locations))
+	for i, location := range locations {
+		statuses[i] = s.presence.Status(location)
 	}
+	return statuses, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+// GetLocationHistory retrieves location history for a vehicle within a time range.
+// An empty operatorID searches across all operators.
+func (s *VehicleService) GetLocationHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64) ([]*models.VehicleLocation, error) {
+	var locations []*models.VehicleLocation
+	err := s.StreamLocationHistory(ctx, operatorID, vehicleID, startTime, endTime, func(location *models.VehicleLocation) error {
+		locations = append(locations, location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return locations, nil
 }
 
+// StreamLocationHistory calls fn for each location of a vehicle within a time range in timestamp order,
+// without loading the whole window into memory. Iteration stops at the first error returned by fn.
+func (s *VehicleService) StreamLocationHistory(ctx context.Context, operatorID, vehicleID string, startTime, endTime int64, fn func(*models.VehicleLocation) error) (err error) {
+	defer metrics.ObserveQuery("StreamLocationHistory", time.Now(), &err)
+
+	return s.locations.StreamHistory(ctx, operatorID, vehicleID, startTime, endTime, fn)
+}
+
+// RegisterVehicle assigns a vehicle to an operator. Locations saved from now on
+// belong to that operator; earlier history keeps the operator it was recorded under.
+func (s *VehicleService) RegisterVehicle(ctx context.Context, vehicleID, operatorID string) (_ *models.Vehicle, err error) {
+	defer metrics.ObserveQuery("RegisterVehicle", time.Now(), &err)
+
+	return s.vehicles.RegisterVehicle(ctx, vehicleID, operatorID)
+}
+
+// ListVehicles lists the registered vehicles of an operator.
+// An empty operatorID lists vehicles of all operators.
+func (s *VehicleService) ListVehicles(ctx context.Context, operatorID string) (_ []*models.Vehicle, err error) {
+	defer metrics.ObserveQuery("ListVehicles", time.Now(), &err)
+
+	return s.vehicles.ListVehicles(ctx, operatorID)
+}
+
 // isInsideGeofence checks if coordinates are within the geofence radius
 func (s *VehicleService) isInsideGeofence(lat, lon float64) bool {
-	distance := s.haversineDistance(
-		s.cfg.GeofenceLatitude,
-		s.cfg.GeofenceLongitude,
+	geofence := s.cfg.Zones().Geofence
+	distance := haversineDistance(
+		geofence.Latitude,
+		geofence.Longitude,
 		lat,
 		lon,
 	)
-	return distance <= s.cfg.GeofenceRadius
+	return distance <= geofence.Radius
 }
 
 // haversineDistance calculates the distance between two points in meters
-func (s *VehicleService) haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const earthRadius = 6371000 // meters
 
 	dLat := toRadians(lat2 - lat1)
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"transjakarta-fleet/internal/config"
+	"transjakarta-fleet/internal/events"
+	"transjakarta-fleet/internal/ingest"
+	"transjakarta-fleet/internal/models"
+	"transjakarta-fleet/internal/repository/memory"
+)
+
+// checkRejection fails t unless err is an *ingest.Rejection with wantReason, or nil if wantReason is empty
+func checkRejection(t *testing.T, err error, wantReason string) {
+	t.Helper()
+
+	if wantReason == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	var rejection *ingest.Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("error = %v, want a rejection with reason %s", err, wantReason)
+	}
+	if rejection.Reason != wantReason {
+		t.Fatalf("reason = %s, want %s", rejection.Reason, wantReason)
+	}
+}
+
+func newTestVehicleService(t *testing.T, cfg *config.Config) (*VehicleService, *memory.Store, *events.Memory) {
+	t.Helper()
+
+	store := memory.New()
+	bus := events.NewMemory()
+	return NewVehicleService(store, store, bus, cfg, NewPresenceMonitor(bus, cfg)), store, bus
+}
+
+func TestVehicleServiceSaveLocation(t *testing.T) {
+	now := time.Now().Unix()
+	kmh := func(v float64) *float64 { return &v }
+
+	// Away from the geofence, and inside it
+	outside := func(timestamp int64) models.VehicleLocation {
+		return models.VehicleLocation{VehicleID: "B1", Latitude: -6.2, Longitude: 106.8, Timestamp: timestamp}
+	}
+	inside := func(cfg *config.Config, timestamp int64) models.VehicleLocation {
+		return models.VehicleLocation{VehicleID: "B1", Latitude: cfg.GeofenceLatitude, Longitude: cfg.GeofenceLongitude, Timestamp: timestamp}
+	}
+
+	tests := []struct {
+		name string
+		mode string // plausibility mode, the default flag if empty
+		// stored are in storage before the service starts, saved go through it first
+		stored   func(cfg *config.Config) []models.VehicleLocation
+		saved    func(cfg *config.Config) []models.VehicleLocation
+		location func(cfg *config.Config) models.VehicleLocation
+
+		wantReason      string
+		wantLate        bool
+		wantImplausible string
+		wantGeofence    int
+		wantDriving     []string
+		wantNewest      int64 // timestamp LastLocation returns afterwards, 0 for none
+		wantHistory     int   // locations stored afterwards
+	}{
+		{
+			name:         "entering the geofence",
+			location:     func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now) },
+			wantGeofence: 1,
+			wantNewest:   now,
+			wantHistory:  1,
+		},
+		{
+			name:        "outside the geofence",
+			location:    func(cfg *config.Config) models.VehicleLocation { return outside(now) },
+			wantNewest:  now,
+			wantHistory: 1,
+		},
+		{
+			name: "speeding",
+			location: func(cfg *config.Config) models.VehicleLocation {
+				location := outside(now)
+				location.Speed = kmh(90)
+				return location
+			},
+			wantDriving: []string{EventSpeeding},
+			wantNewest:  now,
+			wantHistory: 1,
+		},
+		{
+			name:        "late location skips the checks",
+			saved:       func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:    func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now-20) },
+			wantLate:    true,
+			wantNewest:  now - 10,
+			wantHistory: 2,
+		},
+		{
+			name:        "same timestamp as the newest is late",
+			saved:       func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:    func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now-10) },
+			wantLate:    true,
+			wantNewest:  now - 10,
+			wantHistory: 2,
+		},
+		{
+			name:        "newest location read from storage",
+			stored:      func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:    func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now-20) },
+			wantLate:    true,
+			wantNewest:  now - 10,
+			wantHistory: 2,
+		},
+		{
+			name:       "future timestamp",
+			location:   func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now+3600) },
+			wantReason: ingest.ReasonFutureTimestamp,
+		},
+		{
+			name: "null island rejected",
+			mode: config.PlausibilityReject,
+			location: func(cfg *config.Config) models.VehicleLocation {
+				return models.VehicleLocation{VehicleID: "B1", Timestamp: now}
+			},
+			wantReason: ingest.ReasonNullIsland,
+		},
+		{
+			name: "null island flagged by default",
+			location: func(cfg *config.Config) models.VehicleLocation {
+				return models.VehicleLocation{VehicleID: "B1", Timestamp: now}
+			},
+			wantImplausible: ingest.ReasonNullIsland,
+			wantHistory:     1,
+		},
+		{
+			// 3.8 km into the geofence in 10s
+			name:        "impossible jump rejected",
+			mode:        config.PlausibilityReject,
+			saved:       func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:    func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now) },
+			wantReason:  ingest.ReasonImpossibleJump,
+			wantNewest:  now - 10,
+			wantHistory: 1,
+		},
+		{
+			name:            "impossible jump flagged",
+			mode:            config.PlausibilityFlag,
+			saved:           func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:        func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now) },
+			wantImplausible: ingest.ReasonImpossibleJump,
+			wantNewest:      now - 10,
+			wantHistory:     2,
+		},
+		{
+			// The flagged point is not the newest, so the next one is compared with the one before
+			name: "flagged newest location in storage",
+			mode: config.PlausibilityFlag,
+			stored: func(cfg *config.Config) []models.VehicleLocation {
+				flagged := inside(cfg, now-10)
+				flagged.Implausible = ingest.ReasonImpossibleJump
+				return []models.VehicleLocation{outside(now - 20), flagged}
+			},
+			location:    func(cfg *config.Config) models.VehicleLocation { return outside(now - 15) },
+			wantNewest:  now - 15,
+			wantHistory: 3,
+		},
+		{
+			name:         "plausibility filter off",
+			mode:         config.PlausibilityOff,
+			saved:        func(cfg *config.Config) []models.VehicleLocation { return []models.VehicleLocation{outside(now - 10)} },
+			location:     func(cfg *config.Config) models.VehicleLocation { return inside(cfg, now) },
+			wantGeofence: 1,
+			wantDriving:  []string{EventSpeeding},
+			wantNewest:   now,
+			wantHistory:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := newTestConfig(t)
+			if tt.mode != "" {
+				cfg.PlausibilityMode = tt.mode
+			}
+			service, store, bus := newTestVehicleService(t, cfg)
+
+			if tt.stored != nil {
+				for _, location := range tt.stored(cfg) {
+					store.RestoreLocation(location)
+				}
+			}
+			if tt.saved != nil {
+				for _, location := range tt.saved(cfg) {
+					if err := service.SaveLocation(ctx, &location); err != nil {
+						t.Fatalf("saving %+v: %v", location, err)
+					}
+				}
+			}
+			geofenceBefore, drivingBefore := len(bus.GeofenceEvents()), len(bus.DrivingEvents())
+
+			location := tt.location(cfg)
+			checkRejection(t, service.SaveLocation(ctx, &location), tt.wantReason)
+
+			if location.Late != tt.wantLate || location.Implausible != tt.wantImplausible {
+				t.Errorf("late %v, implausible %q, want %v, %q", location.Late, location.Implausible, tt.wantLate, tt.wantImplausible)
+			}
+			if got := len(bus.GeofenceEvents()) - geofenceBefore; got != tt.wantGeofence {
+				t.Errorf("published %d geofence events, want %d", got, tt.wantGeofence)
+			}
+			var driving []string
+			for _, event := range bus.DrivingEvents()[drivingBefore:] {
+				driving = append(driving, event.Event)
+			}
+			if !reflect.DeepEqual(driving, tt.wantDriving) {
+				t.Errorf("driving events = %v, want %v", driving, tt.wantDriving)
+			}
+
+			newest, err := store.LastLocation(ctx, "", "B1")
+			switch {
+			case tt.wantNewest == 0 && err == nil:
+				t.Errorf("LastLocation() = %+v, want none", newest)
+			case tt.wantNewest != 0 && (err != nil || newest.Timestamp != tt.wantNewest):
+				t.Errorf("LastLocation() = %+v, %v, want timestamp %d", newest, err, tt.wantNewest)
+			}
+
+			var history int
+			store.StreamHistory(ctx, "", "B1", 0, now+3600, func(*models.VehicleLocation) error {
+				history++
+				return nil
+			})
+			if history != tt.wantHistory {
+				t.Errorf("stored %d locations, want %d", history, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestVehicleServiceSaveLocationOperator(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	service, _, _ := newTestVehicleService(t, cfg)
+	now := time.Now().Unix()
+
+	if _, err := service.RegisterVehicle(ctx, "B1", "op1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		vehicleID    string
+		wantOperator string
+	}{
+		{"B1", "op1"},
+		{"B2", cfg.SuperTenantID},
+	}
+	for _, tt := range tests {
+		location := &models.VehicleLocation{VehicleID: tt.vehicleID, Latitude: -6.2, Longitude: 106.8, Timestamp: now}
+		if err := service.SaveLocation(ctx, location); err != nil {
+			t.Fatal(err)
+		}
+		if location.OperatorID != tt.wantOperator {
+			t.Errorf("%s saved under operator %q, want %q", tt.vehicleID, location.OperatorID, tt.wantOperator)
+		}
+	}
+}
+
+func TestVehicleServiceSaveLocations(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	cfg.PlausibilityMode = config.PlausibilityReject
+	service, store, _ := newTestVehicleService(t, cfg)
+	now := time.Now().Unix()
+
+	// Sent out of order, with a point at 0,0 among them
+	var locations []*models.VehicleLocation
+	for _, offset := range []int64{10, 30, 20, 40} {
+		locations = append(locations, &models.VehicleLocation{VehicleID: "B1", Latitude: -6.2 + float64(offset)*1e-5, Longitude: 106.8, Timestamp: now - offset})
+	}
+	locations[2].Latitude, locations[2].Longitude = 0, 0
+
+	var order []int64
+	var rejected []string
+	saved, err := service.SaveLocations(ctx, locations, func(location *models.VehicleLocation, err error) {
+		order = append(order, now-location.Timestamp)
+		var rejection *ingest.Rejection
+		if errors.As(err, &rejection) {
+			rejected = append(rejected, rejection.Reason)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved != 3 {
+		t.Errorf("saved %d locations, want 3", saved)
+	}
+	if want := []int64{40, 30, 20, 10}; !reflect.DeepEqual(order, want) {
+		t.Errorf("saved in order %v seconds ago, want %v", order, want)
+	}
+	if want := []string{ingest.ReasonNullIsland}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("rejected %v, want %v", rejected, want)
+	}
+	for _, location := range locations {
+		if location.Late {
+			t.Errorf("location %ds ago saved as late", now-location.Timestamp)
+		}
+	}
+
+	newest, err := store.LastLocation(ctx, "", "B1")
+	if err != nil || newest.Timestamp != now-10 {
+		t.Errorf("LastLocation() = %+v, %v, want the location 10s ago", newest, err)
+	}
+}
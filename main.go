@@ -1,21 +1,35 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	_ "transjakarta-fleet/docs"
 	"transjakarta-fleet/internal/api"
 	"transjakarta-fleet/internal/config"
-	"transjakarta-fleet/internal/database"
+	"transjakarta-fleet/internal/health"
+	"transjakarta-fleet/internal/lifecycle"
+	"transjakarta-fleet/internal/logging"
+	"transjakarta-fleet/internal/metrics"
 	"transjakarta-fleet/internal/mqtt"
-	"transjakarta-fleet/internal/rabbitmq"
+	"transjakarta-fleet/internal/retry"
 	"transjakarta-fleet/internal/services"
+	"transjakarta-fleet/internal/tracing"
+	"transjakarta-fleet/proto"
 )
 
 // @title Transjakarta Fleet Management API
@@ -33,72 +47,249 @@ import (
 // @host localhost:8080
 // @BasePath /api/v1
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	}
+	envErr := godotenv.Load()
 
 	// Initialize configuration
-	cfg := config.LoadConfig()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	flag.Parse()
 
-	// Initialize PostgreSQL database
-	db, err := database.NewPostgresDB(cfg)
+	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Initialize logging
+	if err := logging.Setup(os.Stdout, cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	if *configFile != "" {
+		slog.Info("Loaded configuration file", "path", *configFile)
+	}
+	if envErr != nil {
+		slog.Info("No .env file found, using system environment variables")
 	}
-	defer db.Close()
 
-	// Run migrations
-	if err := database.RunMigrations(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	lc := lifecycle.New(cfg.ShutdownTimeout)
+	lc.Go("config reload", func(ctx context.Context) error {
+		reloadOnSIGHUP(ctx, cfg, *configFile)
+		return nil
+	})
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	if cfg.TracingEnabled {
+		slog.Info("Tracing enabled", "endpoint", cfg.OTLPEndpoint, "sample_ratio", cfg.TracingSampleRatio)
 	}
 
-	// Initialize RabbitMQ
-	rabbitConn, err := rabbitmq.NewRabbitMQ(cfg)
+	// Initialize storage, the event bus and the MQTT broker. External ones connect
+	// in the background so the API can start, reporting not-ready, while they are
+	// still coming up.
+	backends, err := newBackends(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		fatal("Failed to initialize backends", err)
+	}
+	if cfg.Embedded {
+		slog.Info("Running in embedded mode; storage, events and the MQTT broker are in-process", "storage", cfg.Storage)
+	}
+
+	if cfg.AuthEnabled && cfg.AdminAPIKey == "" && cfg.JWTSecret == "" {
+		slog.Warn("Authentication is enabled but neither ADMIN_API_KEY nor JWT_SECRET is set; only existing API keys can access the API")
 	}
-	defer rabbitConn.Close()
 
 	// Initialize services
-	vehicleService := services.NewVehicleService(db, rabbitConn, cfg)
+	presenceMonitor := services.NewPresenceMonitor(backends.publisher, cfg)
+	vehicleService := services.NewVehicleService(backends.store, backends.store, backends.publisher, cfg, presenceMonitor)
+	statsService := services.NewStatsService(backends.store)
+	apiKeyService := services.NewAPIKeyService(backends.store, cfg)
 
 	// Initialize MQTT subscriber
 	mqttClient := mqtt.NewMQTTClient(cfg, vehicleService)
-	if err := mqttClient.Connect(); err != nil {
-		log.Fatalf("Failed to connect to MQTT broker: %v", err)
-	}
-	defer mqttClient.Disconnect()
 
-	// Start geofence worker
-	go rabbitmq.StartGeofenceWorker(rabbitConn)
+	// Connect to dependencies and start workers
+	lc.Go("startup", func(context.Context) error {
+		return connectDependencies(lc, cfg, backends, mqttClient, vehicleService, presenceMonitor)
+	})
 
 	// Initialize Gin router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), logging.Middleware(), metrics.HTTPMiddleware())
 
 	// Setup API routes
-	api.SetupRoutes(router, vehicleService)
+	api.SetupRoutes(router, cfg, vehicleService, statsService, apiKeyService)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "healthy",
-			"service": "transjakarta-fleet-management",
-		})
+	// Protobuf schema of location payloads
+	router.GET("/schemas/location.proto", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", proto.LocationSchema)
 	})
 
+	// Health checks
+	checker := health.NewChecker("transjakarta-fleet-management", cfg.HealthCheckTimeout)
+	backends.registerChecks(checker)
+	checker.Register("mqtt", mqttClient.Check)
+	checker.Register("presence_monitor", presenceMonitor.Check)
+
+	router.GET("/health", checker.ReadinessHandler())
+	router.GET("/health/live", checker.LivenessHandler())
+	router.GET("/health/ready", checker.ReadinessHandler())
+
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	lc.Go("http server", func(ctx context.Context) error {
+		slog.Info("Server starting", "port", cfg.ServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+
+	// Stop accepting requests and locations first, let in-flight writes finish,
+	// stop the workers, then close the brokers and storage
+	lc.OnStop("http server", server.Shutdown)
+	lc.OnStop("mqtt", mqttClient.Stop)
+	lc.OnStop("workers", lc.StopWorkers)
+	if backends.bus != nil {
+		lc.OnStop(backends.busName, func(context.Context) error { return backends.bus.Close() })
+	}
+	if backends.broker != nil {
+		lc.OnStop("mqtt broker", func(context.Context) error { return backends.broker.Close() })
+	}
+	if backends.db != nil {
+		lc.OnStop("database", func(context.Context) error { return backends.db.Close() })
+	}
+	if backends.file != nil {
+		lc.OnStop("storage file", func(context.Context) error { return backends.file.Close() })
+	}
+	lc.OnStop("tracing", shutdownTracing)
+
+	if err := lc.Wait(); err != nil {
+		fatal("Shutdown completed with errors", err)
 	}
+	slog.Info("Shutdown complete")
+}
+
+// connectDependencies waits for storage, the event bus and the MQTT broker in turn,
+// retrying each external one with backoff, and starts the workers that need them.
+// It gives up quietly once shutdown starts and returns an error if a retry policy
+// is exhausted.
+func connectDependencies(
+	lc *lifecycle.Manager,
+	cfg *config.Config,
+	backends *backends,
+	mqttClient *mqtt.MQTTClient,
+	vehicleService *services.VehicleService,
+	presenceMonitor *services.PresenceMonitor,
+) error {
+	ctx := lc.Context()
+
+	// The embedded broker first, the MQTT event bus may publish to it
+	if backends.broker != nil {
+		if err := backends.broker.Start(); err != nil {
+			return err
+		}
+	}
+
+	if err := backends.connectStorage(ctx, cfg); err != nil {
+		return startupError(ctx, err)
+	}
+
+	latest, err := vehicleService.GetLatestLocations(ctx, "")
+	if err != nil {
+		slog.Error("Failed to load last known locations", "error", err)
+	}
+	presenceMonitor.Seed(latest)
 
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Event bus, then the workers that publish to and consume from it
+	if err := backends.connectEventBus(ctx, cfg, lc); err != nil {
+		return startupError(ctx, err)
+	}
+	lc.Go("presence monitor", func(ctx context.Context) error {
+		presenceMonitor.Run(ctx)
+		return nil
+	})
+
+	// MQTT last, so locations only arrive once they can be saved and published
+	err = retry.Do(ctx, "mqtt", cfg.MQTTRetry, func(context.Context) error {
+		return mqttClient.Connect()
+	})
+	if err != nil {
+		return startupError(ctx, err)
 	}
+
+	slog.Info("All dependencies connected")
+	return nil
+}
+
+// reloadOnSIGHUP reloads the configuration on SIGHUP and applies the settings that
+// are safe to change at runtime: the geofence, speed zones and log level. An invalid
+// configuration is logged and the current settings are kept.
+func reloadOnSIGHUP(ctx context.Context, cfg *config.Config, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		reloaded, err := config.Load(path)
+		if err != nil {
+			slog.Error("Failed to reload configuration, keeping current settings", "error", err)
+			continue
+		}
+
+		if err := logging.SetLevel(reloaded.LogLevel); err != nil {
+			slog.Error("Failed to apply log level", "error", err)
+		}
+		zones := reloaded.Zones()
+		cfg.SetZones(zones)
+
+		slog.Info("Configuration reloaded",
+			"log_level", reloaded.LogLevel,
+			"geofence_latitude", zones.Geofence.Latitude,
+			"geofence_longitude", zones.Geofence.Longitude,
+			"geofence_radius", zones.Geofence.Radius,
+			"speed_zones", len(zones.SpeedZones),
+		)
+	}
+}
+
+// startupError drops err when it was caused by shutdown interrupting startup
+func startupError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// fatal logs err and exits, like log.Fatal
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
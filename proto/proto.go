@@ -0,0 +1,9 @@
+// Package proto publishes the Protobuf schemas of the payloads the backend accepts
+package proto
+
+import _ "embed"
+
+// LocationSchema is the schema of Protobuf location payloads
+//
+//go:embed fleet/v1/location.proto
+var LocationSchema []byte